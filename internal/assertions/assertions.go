@@ -0,0 +1,228 @@
+package assertions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/file"
+	"github.com/catmorte/go-mdapi/internal/jsonpath"
+	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
+)
+
+const (
+	StatusType = "status"
+	HeaderType = "header"
+	JSONType   = "json"
+	RegexType  = "regex"
+
+	ReportFile = ".assert"
+)
+
+var typesDescriptions = map[string]string{
+	StatusType: "status code equals (200), is in range (200-299, 2xx) or is one of (200, 201)",
+	HeaderType: "header is present (Content-Type) or has the value (Content-Type: application/json)",
+	JSONType:   "body json path exists ($.data.id) or equals the value ($.data.id = 42)",
+	RegexType:  "body matches the regular expression",
+}
+
+func GetSupportedTypes() []string {
+	return []string{StatusType, HeaderType, JSONType, RegexType}
+}
+
+func GetTypeDescription(key string) (string, error) {
+	d, ok := typesDescriptions[key]
+	if !ok {
+		return "", fmt.Errorf("unknown assert type %s", key)
+	}
+
+	return d, nil
+}
+
+type (
+	Result struct {
+		Nam string
+		Typ string
+		Exp string
+		Err error
+	}
+	Results []Result
+)
+
+func Evaluate(ts file.TypedComponents, vrs varsPkg.Vars) Results {
+	results := make(Results, 0, len(ts))
+	for _, t := range ts {
+		r := Result{Nam: t.Nam, Typ: t.Typ}
+		if len(t.Vals) > 0 {
+			r.Exp, r.Err = varsPkg.Render(t.Vals[0].Val, vrs)
+		}
+		if r.Err == nil {
+			r.Err = evaluate(t.Typ, r.Exp, vrs.GetResultDir())
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+func (rs Results) Failed() bool {
+	for _, r := range rs {
+		if r.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (rs Results) Report() string {
+	sb := strings.Builder{}
+	for _, r := range rs {
+		if r.Err != nil {
+			sb.WriteString(fmt.Sprintf("FAIL %s[%s]: %s\n", r.Nam, r.Typ, r.Err))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("PASS %s[%s]: %s\n", r.Nam, r.Typ, r.Exp))
+	}
+	return sb.String()
+}
+
+func evaluate(typ, exp, resultDir string) error {
+	switch typ {
+	case StatusType:
+		return assertStatus(exp, resultDir)
+	case HeaderType:
+		return assertHeader(exp, resultDir)
+	case JSONType:
+		return assertJSON(exp, resultDir)
+	case RegexType:
+		return assertRegex(exp, resultDir)
+	}
+	return fmt.Errorf("unknown assert type %s", typ)
+}
+
+func readResult(resultDir, name string) (string, error) {
+	raw, err := os.ReadFile(filepath.Join(resultDir, name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("no %s in the result, the type doesn't support this assertion", name)
+		}
+		return "", err
+	}
+	return string(raw), nil
+}
+
+func assertStatus(exp, resultDir string) error {
+	status, err := readResult(resultDir, "status")
+	if err != nil {
+		return err
+	}
+	codeRaw, _, _ := strings.Cut(strings.TrimSpace(status), " ")
+	code, err := strconv.Atoi(codeRaw)
+	if err != nil {
+		return fmt.Errorf("invalid status %q", status)
+	}
+	for _, option := range strings.Split(exp, ",") {
+		ok, err := matchStatus(code, strings.TrimSpace(option))
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("expected status %s, got %d", exp, code)
+}
+
+func matchStatus(code int, option string) (bool, error) {
+	if len(option) == 3 && strings.HasSuffix(strings.ToLower(option), "xx") {
+		class, err := strconv.Atoi(option[:1])
+		if err != nil {
+			return false, fmt.Errorf("invalid status class %s", option)
+		}
+		return code/100 == class, nil
+	}
+	if from, to, ok := strings.Cut(option, "-"); ok {
+		fromCode, err := strconv.Atoi(strings.TrimSpace(from))
+		if err != nil {
+			return false, fmt.Errorf("invalid status range %s", option)
+		}
+		toCode, err := strconv.Atoi(strings.TrimSpace(to))
+		if err != nil {
+			return false, fmt.Errorf("invalid status range %s", option)
+		}
+		return code >= fromCode && code <= toCode, nil
+	}
+	expCode, err := strconv.Atoi(option)
+	if err != nil {
+		return false, fmt.Errorf("invalid status %s", option)
+	}
+	return code == expCode, nil
+}
+
+func assertHeader(exp, resultDir string) error {
+	headers, err := readResult(resultDir, "headers")
+	if err != nil {
+		return err
+	}
+	name, value, hasValue := strings.Cut(exp, ":")
+	name = strings.TrimSpace(name)
+	value = strings.TrimSpace(value)
+	found := false
+	for _, line := range strings.Split(headers, "\n") {
+		key, val, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(key), name) {
+			continue
+		}
+		found = true
+		if !hasValue || strings.TrimSpace(val) == value {
+			return nil
+		}
+	}
+	if !found {
+		return fmt.Errorf("header %s is missing", name)
+	}
+	return fmt.Errorf("header %s doesn't have value %s", name, value)
+}
+
+func assertJSON(exp, resultDir string) error {
+	body, err := readResult(resultDir, "body")
+	if err != nil {
+		return err
+	}
+	path, value, hasValue := strings.Cut(exp, "=")
+	actual, err := jsonpath.LookupBytes([]byte(body), strings.TrimSpace(path))
+	if err != nil {
+		return err
+	}
+	if !hasValue {
+		return nil
+	}
+	value = strings.TrimSpace(value)
+	var expected any
+	if err := json.Unmarshal([]byte(value), &expected); err != nil {
+		expected = value
+	}
+	if jsonpath.String(actual) != jsonpath.String(expected) {
+		return fmt.Errorf("expected %s to be %s, got %s", strings.TrimSpace(path), value, jsonpath.String(actual))
+	}
+	return nil
+}
+
+func assertRegex(exp, resultDir string) error {
+	body, err := readResult(resultDir, "body")
+	if err != nil {
+		return err
+	}
+	r, err := regexp.Compile(exp)
+	if err != nil {
+		return fmt.Errorf("invalid regex: %w", err)
+	}
+	if !r.MatchString(body) {
+		return fmt.Errorf("body doesn't match %s", exp)
+	}
+	return nil
+}
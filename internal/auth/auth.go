@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"encoding/base64"
+	"net/http"
+)
+
+func Basic(rq *http.Request, user, password string) {
+	rq.Header.Set("Authorization", "Basic "+BasicCredentials(user, password))
+}
+
+// BasicCredentials is the encoded user and password of the basic auth header.
+func BasicCredentials(user, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+}
+
+func Bearer(rq *http.Request, token string) {
+	rq.Header.Set("Authorization", "Bearer "+token)
+}
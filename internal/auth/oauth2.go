@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ClientCredentialsGrant = "client_credentials"
+	PasswordGrant          = "password"
+)
+
+// expiryDelta is how long before the expiry a cached token is refreshed.
+const expiryDelta = 30 * time.Second
+
+type (
+	OAuth2 struct {
+		TokenURL     string
+		ClientID     string
+		ClientSecret string
+		Scope        string
+		Grant        string
+		Username     string
+		Password     string
+		// CacheDir keeps fetched tokens until they expire, no caching if empty.
+		CacheDir string
+		Client   *http.Client
+	}
+	Token struct {
+		AccessToken string    `json:"access_token"`
+		TokenType   string    `json:"token_type"`
+		Expiry      time.Time `json:"expiry"`
+	}
+)
+
+func (t Token) Valid(now time.Time) bool {
+	return t.AccessToken != "" && (t.Expiry.IsZero() || t.Expiry.Add(-expiryDelta).After(now))
+}
+
+func (o OAuth2) Token(ctx context.Context) (Token, error) {
+	cachePath := o.cachePath()
+	if cachePath != "" {
+		if t, err := readToken(cachePath); err == nil && t.Valid(time.Now()) {
+			return t, nil
+		}
+	}
+	t, err := o.fetch(ctx)
+	if err != nil {
+		return Token{}, err
+	}
+	if cachePath != "" {
+		err = writeToken(cachePath, t)
+		if err != nil {
+			return Token{}, fmt.Errorf("failed to cache token: %w", err)
+		}
+	}
+	return t, nil
+}
+
+func (o OAuth2) fetch(ctx context.Context) (Token, error) {
+	if o.TokenURL == "" {
+		return Token{}, errors.New("missing oauth token url")
+	}
+	grant := o.Grant
+	if grant == "" {
+		grant = ClientCredentialsGrant
+	}
+	form := url.Values{"grant_type": {grant}}
+	switch grant {
+	case ClientCredentialsGrant:
+	case PasswordGrant:
+		form.Set("username", o.Username)
+		form.Set("password", o.Password)
+	default:
+		return Token{}, fmt.Errorf("unsupported oauth grant %s", grant)
+	}
+	if o.Scope != "" {
+		form.Set("scope", o.Scope)
+	}
+	rq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Token{}, err
+	}
+	rq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rq.Header.Set("Accept", "application/json")
+	if o.ClientID != "" {
+		rq.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(o.ClientSecret))
+	}
+	client := o.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(rq)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to fetch oauth token: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to read oauth token: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Token{}, fmt.Errorf("failed to fetch oauth token: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	raw := struct {
+		AccessToken string      `json:"access_token"`
+		TokenType   string      `json:"token_type"`
+		ExpiresIn   json.Number `json:"expires_in"`
+	}{}
+	err = json.Unmarshal(body, &raw)
+	if err != nil {
+		return Token{}, fmt.Errorf("failed to decode oauth token: %w", err)
+	}
+	if raw.AccessToken == "" {
+		return Token{}, errors.New("oauth token response has no access_token")
+	}
+	t := Token{AccessToken: raw.AccessToken, TokenType: raw.TokenType}
+	if expiresIn, err := raw.ExpiresIn.Int64(); err == nil && expiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+func (o OAuth2) cachePath() string {
+	if o.CacheDir == "" {
+		return ""
+	}
+	// the credentials are part of the key, a token of the old secret or of
+	// another user isn't reused
+	h := sha256.Sum256([]byte(strings.Join([]string{o.TokenURL, o.ClientID, o.ClientSecret, o.Scope, o.Grant, o.Username, o.Password}, "\n")))
+	return filepath.Join(o.CacheDir, hex.EncodeToString(h[:8])+".json")
+}
+
+func readToken(path string) (Token, error) {
+	t := Token{}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return t, err
+	}
+	err = json.Unmarshal(raw, &t)
+	return t, err
+}
+
+func writeToken(path string, t Token) error {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o700)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o600)
+}
@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tokenServer issues token-1, token-2... valid for expiresIn seconds and
+// checks the client credentials.
+func tokenServer(t *testing.T, expiresIn int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	calls := &atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := calls.Add(1)
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != "client" || secret != "secret" {
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		switch r.PostForm.Get("grant_type") {
+		case ClientCredentialsGrant:
+		case PasswordGrant:
+			if r.PostForm.Get("username") != "bob" || r.PostForm.Get("password") != "pw" {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"Bearer","expires_in":%d,"scope":%q}`, n, expiresIn, r.PostForm.Get("scope"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, calls
+}
+
+func TestOAuth2Fetch(t *testing.T) {
+	srv, calls := tokenServer(t, 3600)
+	o := OAuth2{TokenURL: srv.URL, ClientID: "client", ClientSecret: "secret", Scope: "read"}
+
+	tok, err := o.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "token-1" || tok.TokenType != "Bearer" {
+		t.Errorf("token = %+v", tok)
+	}
+	if tok.Expiry.Before(time.Now().Add(59 * time.Minute)) {
+		t.Errorf("expiry = %s, expected in an hour", tok.Expiry)
+	}
+
+	// without a cache dir every call fetches
+	if _, err := o.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("calls = %d, expected 2", calls.Load())
+	}
+}
+
+func TestOAuth2PasswordGrant(t *testing.T) {
+	srv, _ := tokenServer(t, 3600)
+	o := OAuth2{TokenURL: srv.URL, ClientID: "client", ClientSecret: "secret", Grant: PasswordGrant, Username: "bob", Password: "pw"}
+	if _, err := o.Token(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	o.Password = "wrong"
+	if _, err := o.Token(context.Background()); err == nil {
+		t.Error("expected an error for a rejected grant")
+	}
+}
+
+func TestOAuth2Cache(t *testing.T) {
+	srv, calls := tokenServer(t, 3600)
+	dir := t.TempDir()
+	o := OAuth2{TokenURL: srv.URL, ClientID: "client", ClientSecret: "secret", CacheDir: dir}
+
+	first, err := o.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := o.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 1 || second.AccessToken != first.AccessToken {
+		t.Errorf("calls = %d, tokens %s and %s, expected the cached token", calls.Load(), first.AccessToken, second.AccessToken)
+	}
+
+	// another scope is another token
+	o.Scope = "write"
+	third, err := o.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 || third.AccessToken == first.AccessToken {
+		t.Errorf("calls = %d, token %s, expected a new token for another scope", calls.Load(), third.AccessToken)
+	}
+}
+
+func TestOAuth2CacheCredentials(t *testing.T) {
+	srv, calls := tokenServer(t, 3600)
+	dir := t.TempDir()
+	for _, tt := range []struct {
+		name   string
+		o      OAuth2
+		change func(*OAuth2)
+	}{
+		{"secret", OAuth2{TokenURL: srv.URL, ClientID: "client", ClientSecret: "secret"}, func(o *OAuth2) { o.ClientSecret = "rotated" }},
+		{"password", OAuth2{TokenURL: srv.URL, ClientID: "client", ClientSecret: "secret", Grant: PasswordGrant, Username: "bob", Password: "pw"}, func(o *OAuth2) { o.Password = "changed" }},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.o
+			o.CacheDir = dir
+			if _, err := o.Token(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			before := calls.Load()
+			// the token server rejects the new credentials, the token cached
+			// for the old ones mustn't be returned instead
+			tt.change(&o)
+			if tok, err := o.Token(context.Background()); err == nil {
+				t.Errorf("got %s, expected the changed credentials to be sent", tok.AccessToken)
+			}
+			if calls.Load() != before+1 {
+				t.Errorf("calls = %d, want %d", calls.Load(), before+1)
+			}
+		})
+	}
+}
+
+func TestOAuth2CacheExpiry(t *testing.T) {
+	// tokens expiring within the expiry delta are refreshed right away
+	srv, calls := tokenServer(t, int(expiryDelta/time.Second)-1)
+	o := OAuth2{TokenURL: srv.URL, ClientID: "client", ClientSecret: "secret", CacheDir: t.TempDir()}
+
+	first, err := o.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := o.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls.Load() != 2 || second.AccessToken == first.AccessToken {
+		t.Errorf("calls = %d, tokens %s and %s, expected the expiring token to be refreshed", calls.Load(), first.AccessToken, second.AccessToken)
+	}
+}
+
+func TestTokenValid(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		token Token
+		want  bool
+	}{
+		{"empty", Token{}, false},
+		{"no expiry", Token{AccessToken: "t"}, true},
+		{"valid", Token{AccessToken: "t", Expiry: now.Add(time.Hour)}, true},
+		{"within delta", Token{AccessToken: "t", Expiry: now.Add(expiryDelta / 2)}, false},
+		{"expired", Token{AccessToken: "t", Expiry: now.Add(-time.Minute)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.token.Valid(now); got != tt.want {
+				t.Errorf("Valid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
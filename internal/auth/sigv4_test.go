@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the example of the aws docs (signature version 4, iam ListUsers)
+func TestSignV4KnownVector(t *testing.T) {
+	rq, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rq.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	creds := AWSCredentials{AccessKey: "AKIDEXAMPLE", SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+
+	err = SignV4(rq, creds, "us-east-1", "iam", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+		"SignedHeaders=content-type;host;x-amz-date, " +
+		"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+	if got := rq.Header.Get("Authorization"); got != want {
+		t.Errorf("Authorization:\n got %s\nwant %s", got, want)
+	}
+	if got := rq.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %s", got)
+	}
+}
+
+func TestSignV4S3PayloadHash(t *testing.T) {
+	rq, err := http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/key", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds := AWSCredentials{AccessKey: "AKIDEXAMPLE", SecretKey: "secret", SessionToken: "session"}
+
+	err = SignV4(rq, creds, "us-east-1", "s3", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sha256 of "hello"
+	if got := rq.Header.Get("X-Amz-Content-Sha256"); got != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("X-Amz-Content-Sha256 = %s", got)
+	}
+	if got := rq.Header.Get("X-Amz-Security-Token"); got != "session" {
+		t.Errorf("X-Amz-Security-Token = %s", got)
+	}
+	if !strings.Contains(rq.Header.Get("Authorization"), "SignedHeaders=host;x-amz-content-sha256;x-amz-date;x-amz-security-token,") {
+		t.Errorf("Authorization = %s", rq.Header.Get("Authorization"))
+	}
+	body, err := io.ReadAll(rq.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "hello" {
+		t.Errorf("body = %q, the signed body has to be restored", body)
+	}
+}
+
+func TestSignV4MissingCredentials(t *testing.T) {
+	rq, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SignV4(rq, AWSCredentials{}, "us-east-1", "iam", time.Now()); err == nil {
+		t.Error("expected an error without credentials")
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os/exec"
 	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/secrets"
 )
 
 func RunCommand(command string) (string, error) {
@@ -22,7 +24,7 @@ func RunCommand(command string) (string, error) {
 	// Run the command
 	err := cmd.Run()
 	if err != nil {
-		return "", fmt.Errorf("failed to run command: %v, stderr: %s", err, errOut.String())
+		return "", fmt.Errorf("failed to run command: %v, stderr: %s", err, secrets.Redact(errOut.String()))
 	}
 
 	// Return the output
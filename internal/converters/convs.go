@@ -97,3 +97,8 @@ func SupportedConvs() []string {
 	}
 	return res
 }
+
+func IsSupported(name string) bool {
+	_, ok := convs[name]
+	return ok
+}
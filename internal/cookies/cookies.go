@@ -0,0 +1,192 @@
+package cookies
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	Entry struct {
+		Name     string    `json:"name"`
+		Value    string    `json:"value"`
+		Domain   string    `json:"domain"`
+		Path     string    `json:"path"`
+		Expires  time.Time `json:"expires,omitempty"`
+		Secure   bool      `json:"secure,omitempty"`
+		HttpOnly bool      `json:"httpOnly,omitempty"`
+		HostOnly bool      `json:"hostOnly,omitempty"`
+	}
+	// Jar is a http.CookieJar which can be saved to and loaded from a file.
+	Jar struct {
+		mu      sync.Mutex
+		entries []Entry
+	}
+)
+
+func Path(resultFolder, name string) string {
+	return filepath.Join(resultFolder, ".cookies", name+".json")
+}
+
+func Load(path string) (*Jar, error) {
+	j := &Jar{}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return j, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(raw, &j.entries)
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (j *Jar) Save(path string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.removeExpired(time.Now())
+	raw, err := json.MarshalIndent(j.entries, "", " ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o600)
+}
+
+func (j *Jar) Entries() []Entry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.removeExpired(time.Now())
+	return append([]Entry{}, j.entries...)
+}
+
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	host := canonicalHost(u.Host)
+	for _, c := range cookies {
+		e := Entry{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		if e.Path == "" || !strings.HasPrefix(e.Path, "/") {
+			e.Path = defaultPath(u.Path)
+		}
+		domain := strings.TrimPrefix(strings.ToLower(c.Domain), ".")
+		if domain == "" {
+			e.Domain = host
+			e.HostOnly = true
+		} else {
+			if host != domain && !strings.HasSuffix(host, "."+domain) {
+				continue
+			}
+			e.Domain = domain
+		}
+		switch {
+		case c.MaxAge < 0:
+			e.Expires = now.Add(-time.Second)
+		case c.MaxAge > 0:
+			e.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		case !c.Expires.IsZero():
+			e.Expires = c.Expires
+		}
+		j.set(e)
+	}
+	j.removeExpired(now)
+}
+
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	now := time.Now()
+	host := canonicalHost(u.Host)
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	res := []*http.Cookie{}
+	for _, e := range j.entries {
+		if !e.Expires.IsZero() && !e.Expires.After(now) {
+			continue
+		}
+		if e.Secure && u.Scheme != "https" {
+			continue
+		}
+		if e.HostOnly && host != e.Domain {
+			continue
+		}
+		if !e.HostOnly && host != e.Domain && !strings.HasSuffix(host, "."+e.Domain) {
+			continue
+		}
+		if !pathMatch(path, e.Path) {
+			continue
+		}
+		res = append(res, &http.Cookie{Name: e.Name, Value: e.Value})
+	}
+	return res
+}
+
+func (j *Jar) set(e Entry) {
+	for i, v := range j.entries {
+		if v.Name == e.Name && v.Domain == e.Domain && v.Path == e.Path {
+			j.entries[i] = e
+			return
+		}
+	}
+	j.entries = append(j.entries, e)
+}
+
+func (j *Jar) removeExpired(now time.Time) {
+	entries := j.entries[:0]
+	for _, e := range j.entries {
+		if e.Expires.IsZero() || e.Expires.After(now) {
+			entries = append(entries, e)
+		}
+	}
+	j.entries = entries
+}
+
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
+
+func defaultPath(path string) string {
+	if path == "" || path[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(path, "/")
+	if i == 0 {
+		return "/"
+	}
+	return path[:i]
+}
+
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
@@ -0,0 +1,128 @@
+package env
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/parser"
+	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
+)
+
+var ErrNotExist = errors.New("no env defined")
+
+func Candidates(name, curdir, cfgPath string) []string {
+	return []string{
+		filepath.Join(curdir, "envs", name+".md"),
+		filepath.Join(curdir, "envs", name+".env"),
+		filepath.Join(curdir, ".env."+name),
+		filepath.Join(cfgPath, "envs", name+".md"),
+		filepath.Join(cfgPath, "envs", name+".env"),
+	}
+}
+
+func Find(name, curdir, cfgPath string) (string, error) {
+	for _, v := range Candidates(name, curdir, cfgPath) {
+		info, err := os.Stat(v)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return "", err
+		}
+		if !info.IsDir() {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrNotExist, name)
+}
+
+func Load(envPath string) (map[string]string, error) {
+	if filepath.Ext(envPath) == ".md" {
+		return loadMarkdown(envPath)
+	}
+	return loadDotEnv(envPath)
+}
+
+// Apply loads the env into vars without overriding already set keys and
+// returns the loaded env file path and the keys taken from it.
+func Apply(name, curdir, cfgPath string, vars map[string]string) (string, []string, error) {
+	if name == "" {
+		return "", nil, nil
+	}
+	envPath, err := Find(name, curdir, cfgPath)
+	if err != nil {
+		return "", nil, err
+	}
+	envVars, err := Load(envPath)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to load env %s: %w", envPath, err)
+	}
+	applied := []string{}
+	for k, v := range envVars {
+		if _, ok := vars[k]; ok {
+			continue
+		}
+		vars[k] = v
+		applied = append(applied, k)
+	}
+	return envPath, applied, nil
+}
+
+func loadMarkdown(envPath string) (map[string]string, error) {
+	envVars := varsPkg.Vars{}
+	envVars.SetCurrentDir(filepath.Dir(envPath))
+	f, err := parser.ParseMarkdownFile(envPath, envVars)
+	if err != nil {
+		return nil, err
+	}
+	err = f.Vars.Compute(envVars, true)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]string{}
+	for _, v := range f.Vars {
+		res[v.Nam] = envVars[v.Nam]
+	}
+	return res, nil
+}
+
+func loadDotEnv(envPath string) (map[string]string, error) {
+	file, err := os.Open(envPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	res := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, val, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid env line %d: %s", lineNum, line)
+		}
+		val = strings.TrimSpace(val)
+		if strings.HasPrefix(val, "\"") {
+			unquoted, err := strconv.Unquote(val)
+			if err != nil {
+				return nil, fmt.Errorf("invalid env line %d: %w", lineNum, err)
+			}
+			val = unquoted
+		} else if len(val) > 1 && strings.HasPrefix(val, "'") && strings.HasSuffix(val, "'") {
+			val = val[1 : len(val)-1]
+		}
+		res[strings.TrimSpace(key)] = val
+	}
+	return res, scanner.Err()
+}
@@ -0,0 +1,444 @@
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/catmorte/go-mdapi/internal/types"
+	"github.com/catmorte/go-mdapi/internal/vars"
+)
+
+type (
+	FormField struct {
+		Name  string
+		Value string
+		File  bool
+	}
+	Header struct {
+		Name  string
+		Value string
+	}
+	// Auth is the basic or awsv4 auth of the request, bearer and oauth2
+	// tokens are sent as an Authorization header.
+	Auth struct {
+		Kind         string
+		User         string
+		Password     string
+		SessionToken string
+		Region       string
+		Service      string
+	}
+	Request struct {
+		Nam      string
+		Method   string
+		URL      string
+		Headers  []Header
+		Body     string
+		BodyFile string
+		Form     []FormField
+		Auth     Auth
+
+		Insecure        bool
+		CACert          string
+		Cert            string
+		Key             string
+		Proxy           string
+		Timeout         time.Duration
+		FollowRedirects bool
+		Cookies         bool
+	}
+)
+
+func Formats() []string {
+	formats := make([]string, 0, len(exporters)+1)
+	for k := range exporters {
+		formats = append(formats, k)
+	}
+	formats = append(formats, "postman")
+	sort.Strings(formats)
+	return formats
+}
+
+var exporters = map[string]func(Request) string{
+	"curl":   Curl,
+	"httpie": HTTPie,
+	"go":     Go,
+}
+
+// supported lists the options each format can express, the others are
+// reported by Unsupported.
+var supported = map[string][]string{
+	"curl":    {"basic", "awsv4", "insecure", "cacert", "cert", "proxy", "timeout", "followRedirects"},
+	"httpie":  {"basic", "insecure", "cacert", "cert", "proxy", "timeout", "followRedirects"},
+	"go":      {"basic", "timeout", "followRedirects"},
+	"postman": {"basic", "awsv4", "insecure", "followRedirects"},
+}
+
+func Export(format string, rq Request) (string, error) {
+	e, ok := exporters[format]
+	if !ok {
+		if format == "postman" {
+			return Postman(rq.Nam, []PostmanEntry{{Path: rq.Nam, Request: rq}})
+		}
+		return "", fmt.Errorf("unknown export format %s", format)
+	}
+	return e(rq), nil
+}
+
+// Unsupported describes the fields of the request which are lost when
+// exported to the format.
+func Unsupported(format string, rq Request) []string {
+	used := []string{}
+	if rq.Auth.Kind == types.AuthBasic || rq.Auth.Kind == types.AuthAWSV4 {
+		used = append(used, rq.Auth.Kind)
+	}
+	if rq.Insecure {
+		used = append(used, "insecure")
+	}
+	if rq.CACert != "" {
+		used = append(used, "cacert")
+	}
+	if rq.Cert != "" {
+		used = append(used, "cert")
+	}
+	if rq.Proxy != "" {
+		used = append(used, "proxy")
+	}
+	if rq.Timeout > 0 {
+		used = append(used, "timeout")
+	}
+	if !rq.FollowRedirects {
+		used = append(used, "followRedirects")
+	}
+	if rq.Cookies {
+		used = append(used, "cookies")
+	}
+	warnings := []string{}
+	if rq.Auth.Kind == types.AuthOAuth2 {
+		warnings = append(warnings, "the oauth2 token isn't fetched, the Authorization header has a placeholder")
+	}
+	for _, v := range used {
+		if !slices.Contains(supported[format], v) {
+			warnings = append(warnings, fmt.Sprintf("%s isn't supported by %s and is dropped", v, format))
+		}
+	}
+	return warnings
+}
+
+// FromVars reads the request with the field parsing of the http type.
+func FromVars(nam string, vrs vars.Vars) (Request, error) {
+	rq := Request{Nam: nam}
+	var ok bool
+	rq.URL, ok = types.InternalHTTPURLField.Get(vrs)
+	if !ok {
+		return rq, fmt.Errorf("missing url field")
+	}
+	rq.Method, ok = types.InternalHTTPMethodField.Get(vrs)
+	if !ok {
+		rq.Method = http.MethodGet
+	}
+	if headersRaw, ok := types.InternalHTTPHeadersField.Get(vrs); ok {
+		headers, err := types.ParseHeaders(headersRaw)
+		if err != nil {
+			return rq, err
+		}
+		for _, h := range headers {
+			rq.Headers = append(rq.Headers, Header{Name: h.Name, Value: h.Value})
+		}
+	}
+	err := rq.options(vrs)
+	if err != nil {
+		return rq, err
+	}
+	if body, ok := types.InternalHTTPBodyField.Get(vrs); ok {
+		rq.Body = body
+		return rq, nil
+	}
+	if bodyFile, ok := types.InternalHTTPBodyFileField.Get(vrs); ok {
+		rq.BodyFile = bodyFile
+		return rq, nil
+	}
+	if form, ok := types.InternalHTTPFormField.Get(vrs); ok {
+		parts, err := types.ParseForm(form)
+		if err != nil {
+			return rq, err
+		}
+		for _, p := range parts {
+			rq.Form = append(rq.Form, FormField{Name: p.Name, Value: p.Value, File: p.File})
+		}
+	}
+	return rq, nil
+}
+
+func (rq *Request) options(vrs vars.Vars) error {
+	a, err := types.ParseHTTPAuth(vrs)
+	if err != nil {
+		return err
+	}
+	rq.Auth.Kind = a.Kind
+	switch a.Kind {
+	case types.AuthBasic:
+		rq.Auth.User = a.User
+		rq.Auth.Password = a.Password
+	case types.AuthBearer, types.AuthOAuth2:
+		token := a.Token
+		if a.Kind == types.AuthOAuth2 {
+			token = types.OAuth2Placeholder
+		}
+		if !hasHeader(rq.Headers, "Authorization") {
+			rq.Headers = append(rq.Headers, Header{Name: "Authorization", Value: "Bearer " + token})
+		}
+	case types.AuthAWSV4:
+		rq.Auth.User = a.AWS.AccessKey
+		rq.Auth.Password = a.AWS.SecretKey
+		rq.Auth.SessionToken = a.AWS.SessionToken
+		rq.Auth.Region = a.Region
+		rq.Auth.Service = a.Service
+	}
+
+	rq.Insecure, err = types.InternalHTTPInsecureField.GetBool(vrs, false)
+	if err != nil {
+		return err
+	}
+	rq.FollowRedirects, err = types.InternalHTTPFollowRedirectsField.GetBool(vrs, true)
+	if err != nil {
+		return err
+	}
+	rq.Timeout, err = types.InternalHTTPTimeoutField.GetDuration(vrs)
+	if err != nil {
+		return err
+	}
+	rq.CACert = types.InternalHTTPCACertField.GetTrimmed(vrs)
+	rq.Cert = types.InternalHTTPCertField.GetTrimmed(vrs)
+	rq.Key = types.InternalHTTPKeyField.GetTrimmed(vrs)
+	rq.Proxy = types.InternalHTTPProxyField.GetTrimmed(vrs)
+	jar := types.InternalHTTPCookieJarField.GetTrimmed(vrs)
+	rq.Cookies, err = types.InternalHTTPCookiesField.GetBool(vrs, jar != "")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', -1, 64)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func Curl(rq Request) string {
+	sb := strings.Builder{}
+	sb.WriteString("curl -X " + rq.Method + " " + shellQuote(rq.URL))
+	switch rq.Auth.Kind {
+	case types.AuthBasic:
+		sb.WriteString(" \\\n  -u " + shellQuote(rq.Auth.User+":"+rq.Auth.Password))
+	case types.AuthAWSV4:
+		sb.WriteString(" \\\n  --aws-sigv4 " + shellQuote("aws:amz:"+rq.Auth.Region+":"+rq.Auth.Service))
+		sb.WriteString(" \\\n  -u " + shellQuote(rq.Auth.User+":"+rq.Auth.Password))
+		if rq.Auth.SessionToken != "" {
+			sb.WriteString(" \\\n  -H " + shellQuote("X-Amz-Security-Token: "+rq.Auth.SessionToken))
+		}
+	}
+	for _, h := range rq.Headers {
+		sb.WriteString(" \\\n  -H " + shellQuote(h.Name+": "+h.Value))
+	}
+	if rq.FollowRedirects {
+		sb.WriteString(" \\\n  -L")
+	}
+	if rq.Insecure {
+		sb.WriteString(" \\\n  -k")
+	}
+	if rq.CACert != "" {
+		sb.WriteString(" \\\n  --cacert " + shellQuote(rq.CACert))
+	}
+	if rq.Cert != "" {
+		sb.WriteString(" \\\n  --cert " + shellQuote(rq.Cert) + " --key " + shellQuote(rq.Key))
+	}
+	if rq.Proxy != "" {
+		sb.WriteString(" \\\n  -x " + shellQuote(rq.Proxy))
+	}
+	if rq.Timeout > 0 {
+		sb.WriteString(" \\\n  -m " + seconds(rq.Timeout))
+	}
+	switch {
+	case rq.BodyFile != "":
+		sb.WriteString(" \\\n  --data-binary " + shellQuote("@"+rq.BodyFile))
+	case len(rq.Form) > 0:
+		for _, f := range rq.Form {
+			if f.File {
+				sb.WriteString(" \\\n  -F " + shellQuote(f.Name+"=@"+f.Value))
+				continue
+			}
+			sb.WriteString(" \\\n  --form-string " + shellQuote(f.Name+"="+f.Value))
+		}
+	case rq.Body != "":
+		sb.WriteString(" \\\n  --data-raw " + shellQuote(rq.Body))
+	}
+	return sb.String()
+}
+
+func HTTPie(rq Request) string {
+	sb := strings.Builder{}
+	sb.WriteString("http")
+	if len(rq.Form) > 0 {
+		sb.WriteString(" --multipart")
+	}
+	if rq.FollowRedirects {
+		sb.WriteString(" --follow")
+	}
+	if rq.Insecure {
+		sb.WriteString(" --verify=no")
+	} else if rq.CACert != "" {
+		sb.WriteString(" --verify=" + shellQuote(rq.CACert))
+	}
+	if rq.Cert != "" {
+		sb.WriteString(" --cert=" + shellQuote(rq.Cert) + " --cert-key=" + shellQuote(rq.Key))
+	}
+	if rq.Proxy != "" {
+		scheme := "http"
+		if u, err := url.Parse(rq.URL); err == nil && u.Scheme != "" {
+			scheme = u.Scheme
+		}
+		sb.WriteString(" --proxy=" + shellQuote(scheme+":"+rq.Proxy))
+	}
+	if rq.Timeout > 0 {
+		sb.WriteString(" --timeout=" + seconds(rq.Timeout))
+	}
+	if rq.Auth.Kind == types.AuthBasic {
+		sb.WriteString(" -a " + shellQuote(rq.Auth.User+":"+rq.Auth.Password))
+	}
+	sb.WriteString(" " + rq.Method + " " + shellQuote(rq.URL))
+	for _, h := range rq.Headers {
+		sb.WriteString(" \\\n  " + shellQuote(h.Name+":"+h.Value))
+	}
+	switch {
+	case rq.BodyFile != "":
+		sb.WriteString(" \\\n  < " + shellQuote(rq.BodyFile))
+	case len(rq.Form) > 0:
+		for _, f := range rq.Form {
+			if f.File {
+				sb.WriteString(" \\\n  " + shellQuote(f.Name+"@"+f.Value))
+				continue
+			}
+			sb.WriteString(" \\\n  " + shellQuote(f.Name+"="+f.Value))
+		}
+	case rq.Body != "":
+		sb.WriteString(" \\\n  --raw " + shellQuote(rq.Body))
+	}
+	return sb.String()
+}
+
+func Go(rq Request) string {
+	imports := []string{"fmt", "io", "net/http", "os"}
+	body := "http.NoBody"
+	prepare := ""
+	switch {
+	case rq.BodyFile != "":
+		prepare = fmt.Sprintf(`	body, err := os.Open(%q)
+	if err != nil {
+		panic(err)
+	}
+	defer body.Close()
+`, rq.BodyFile)
+		body = "body"
+	case len(rq.Form) > 0:
+		imports = append(imports, "bytes", "mime/multipart", "path/filepath")
+		sb := strings.Builder{}
+		sb.WriteString("\tbody := &bytes.Buffer{}\n\twriter := multipart.NewWriter(body)\n")
+		for _, f := range rq.Form {
+			if f.File {
+				sb.WriteString(fmt.Sprintf(`	{
+		file, err := os.Open(%[2]q)
+		if err != nil {
+			panic(err)
+		}
+		defer file.Close()
+		part, err := writer.CreateFormFile(%[1]q, filepath.Base(%[2]q))
+		if err != nil {
+			panic(err)
+		}
+		if _, err := io.Copy(part, file); err != nil {
+			panic(err)
+		}
+	}
+`, f.Name, f.Value))
+				continue
+			}
+			sb.WriteString(fmt.Sprintf("\tif err := writer.WriteField(%q, %q); err != nil {\n\t\tpanic(err)\n\t}\n", f.Name, f.Value))
+		}
+		sb.WriteString("\tif err := writer.Close(); err != nil {\n\t\tpanic(err)\n\t}\n")
+		prepare = sb.String()
+		body = "body"
+	case rq.Body != "":
+		imports = append(imports, "strings")
+		body = fmt.Sprintf("strings.NewReader(%s)", goString(rq.Body))
+	}
+	if rq.Timeout > 0 {
+		imports = append(imports, "time")
+	}
+	sort.Strings(imports)
+
+	sb := strings.Builder{}
+	sb.WriteString("package main\n\nimport (\n")
+	for _, v := range imports {
+		sb.WriteString(fmt.Sprintf("\t%q\n", v))
+	}
+	sb.WriteString(")\n\nfunc main() {\n")
+	sb.WriteString(prepare)
+	sb.WriteString(fmt.Sprintf("\trq, err := http.NewRequest(%q, %q, %s)\n", rq.Method, rq.URL, body))
+	sb.WriteString("\tif err != nil {\n\t\tpanic(err)\n\t}\n")
+	for _, h := range rq.Headers {
+		sb.WriteString(fmt.Sprintf("\trq.Header.Add(%q, %q)\n", h.Name, h.Value))
+	}
+	if rq.Auth.Kind == types.AuthBasic {
+		sb.WriteString(fmt.Sprintf("\trq.SetBasicAuth(%q, %q)\n", rq.Auth.User, rq.Auth.Password))
+	}
+	if len(rq.Form) > 0 && !hasHeader(rq.Headers, "Content-Type") {
+		sb.WriteString("\trq.Header.Set(\"Content-Type\", writer.FormDataContentType())\n")
+	}
+	client := "http.DefaultClient"
+	if rq.Timeout > 0 || !rq.FollowRedirects {
+		client = "client"
+		sb.WriteString("\tclient := &http.Client{\n")
+		if rq.Timeout > 0 {
+			sb.WriteString(fmt.Sprintf("\t\tTimeout: %d * time.Millisecond,\n", rq.Timeout.Milliseconds()))
+		}
+		if !rq.FollowRedirects {
+			sb.WriteString("\t\tCheckRedirect: func(*http.Request, []*http.Request) error {\n\t\t\treturn http.ErrUseLastResponse\n\t\t},\n")
+		}
+		sb.WriteString("\t}\n")
+	}
+	sb.WriteString(`	resp, err := ` + client + `.Do(rq)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+	fmt.Println(resp.Status)
+	if _, err := io.Copy(os.Stdout, resp.Body); err != nil {
+		panic(err)
+	}
+}
+`)
+	return sb.String()
+}
+
+func goString(s string) string {
+	if !strings.Contains(s, "`") && strings.Contains(s, "\n") {
+		return "`" + s + "`"
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+func hasHeader(headers []Header, name string) bool {
+	for _, h := range headers {
+		if strings.EqualFold(h.Name, name) {
+			return true
+		}
+	}
+	return false
+}
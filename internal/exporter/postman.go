@@ -0,0 +1,151 @@
+package exporter
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/types"
+)
+
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+type (
+	PostmanEntry struct {
+		Path    string
+		Request Request
+	}
+	postmanItem struct {
+		Name     string          `json:"name"`
+		Item     []*postmanItem  `json:"item,omitempty"`
+		Request  *postmanRequest `json:"request,omitempty"`
+		Behavior map[string]bool `json:"protocolProfileBehavior,omitempty"`
+	}
+	postmanRequest struct {
+		Method string            `json:"method"`
+		Header []postmanKeyValue `json:"header"`
+		URL    postmanURL        `json:"url"`
+		Body   *postmanBody      `json:"body,omitempty"`
+		Auth   map[string]any    `json:"auth,omitempty"`
+	}
+	postmanURL struct {
+		Raw string `json:"raw"`
+	}
+	postmanBody struct {
+		Mode     string            `json:"mode"`
+		Raw      string            `json:"raw,omitempty"`
+		FormData []postmanKeyValue `json:"formdata,omitempty"`
+		File     *postmanFile      `json:"file,omitempty"`
+	}
+	postmanFile struct {
+		Src string `json:"src"`
+	}
+	postmanKeyValue struct {
+		Key   string `json:"key"`
+		Value string `json:"value,omitempty"`
+		Type  string `json:"type,omitempty"`
+		Src   string `json:"src,omitempty"`
+	}
+)
+
+// Postman builds a v2.1 collection, entries with a / in the path are put
+// into folders.
+func Postman(name string, entries []PostmanEntry) (string, error) {
+	root := &postmanItem{Name: name}
+	for _, e := range entries {
+		parts := strings.Split(strings.Trim(e.Path, "/"), "/")
+		folder := root
+		for _, p := range parts[:len(parts)-1] {
+			folder = folder.folder(p)
+		}
+		folder.Item = append(folder.Item, &postmanItem{
+			Name:     parts[len(parts)-1],
+			Request:  postmanRequestOf(e.Request),
+			Behavior: postmanBehavior(e.Request),
+		})
+	}
+	collection := map[string]any{
+		"info": map[string]string{
+			"name":   name,
+			"schema": postmanSchema,
+		},
+		"item": root.Item,
+	}
+	raw, err := json.MarshalIndent(collection, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+func (p *postmanItem) folder(name string) *postmanItem {
+	for _, v := range p.Item {
+		if v.Request == nil && v.Name == name {
+			return v
+		}
+	}
+	f := &postmanItem{Name: name}
+	p.Item = append(p.Item, f)
+	return f
+}
+
+func postmanRequestOf(rq Request) *postmanRequest {
+	res := &postmanRequest{
+		Method: rq.Method,
+		Header: []postmanKeyValue{},
+		URL:    postmanURL{Raw: rq.URL},
+	}
+	for _, h := range rq.Headers {
+		res.Header = append(res.Header, postmanKeyValue{Key: h.Name, Value: h.Value})
+	}
+	switch rq.Auth.Kind {
+	case types.AuthBasic:
+		res.Auth = map[string]any{
+			"type": "basic",
+			"basic": []postmanKeyValue{
+				{Key: "username", Value: rq.Auth.User, Type: "string"},
+				{Key: "password", Value: rq.Auth.Password, Type: "string"},
+			},
+		}
+	case types.AuthAWSV4:
+		res.Auth = map[string]any{
+			"type": "awsv4",
+			"awsv4": []postmanKeyValue{
+				{Key: "accessKey", Value: rq.Auth.User, Type: "string"},
+				{Key: "secretKey", Value: rq.Auth.Password, Type: "string"},
+				{Key: "sessionToken", Value: rq.Auth.SessionToken, Type: "string"},
+				{Key: "region", Value: rq.Auth.Region, Type: "string"},
+				{Key: "service", Value: rq.Auth.Service, Type: "string"},
+			},
+		}
+	}
+	switch {
+	case rq.BodyFile != "":
+		res.Body = &postmanBody{Mode: "file", File: &postmanFile{Src: rq.BodyFile}}
+	case len(rq.Form) > 0:
+		res.Body = &postmanBody{Mode: "formdata"}
+		for _, f := range rq.Form {
+			if f.File {
+				res.Body.FormData = append(res.Body.FormData, postmanKeyValue{Key: f.Name, Type: "file", Src: f.Value})
+				continue
+			}
+			res.Body.FormData = append(res.Body.FormData, postmanKeyValue{Key: f.Name, Value: f.Value, Type: "text"})
+		}
+	case rq.Body != "":
+		res.Body = &postmanBody{Mode: "raw", Raw: rq.Body}
+	}
+	return res
+}
+
+func postmanBehavior(rq Request) map[string]bool {
+	behavior := map[string]bool{}
+	if !rq.FollowRedirects {
+		behavior["followRedirects"] = false
+	}
+	if rq.Insecure {
+		behavior["strictSSL"] = false
+	}
+	if len(behavior) == 0 {
+		return nil
+	}
+	return behavior
+}
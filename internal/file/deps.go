@@ -0,0 +1,199 @@
+package file
+
+import (
+	"errors"
+	"slices"
+	"strings"
+
+	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
+)
+
+func (f File) CheckReferences(vars varsPkg.Vars) error {
+	return f.ReferenceDiagnostics(vars).Err()
+}
+
+// ReferenceDiagnostics reports references to vars which are neither set,
+// provided nor declared before use and cycles between the components. The
+// sections are computed in order (vars, type fields, after), so a component
+// can only reference its own or an earlier section.
+func (f File) ReferenceDiagnostics(vars varsPkg.Vars) Diagnostics {
+	sections := []struct {
+		nam string
+		ts  TypedComponents
+	}{
+		{"vars", f.Vars},
+		{"type", f.Typ.Fields},
+		{"after", f.After},
+	}
+	declaredIn := map[string]string{}
+	for i := len(sections) - 1; i >= 0; i-- {
+		for _, t := range sections[i].ts {
+			declaredIn[t.Nam] = sections[i].nam
+		}
+	}
+
+	defined := map[string]bool{}
+	for k := range vars {
+		defined[k] = true
+	}
+	provided := map[string]bool{}
+	for _, k := range f.Provided {
+		provided[k] = true
+	}
+	diags := Diagnostics{}
+	for i, sec := range sections {
+		local := map[string]bool{}
+		for _, t := range sec.ts {
+			local[t.Nam] = true
+		}
+		for _, t := range sec.ts {
+			if i == 0 && defined[t.Nam] {
+				continue
+			}
+			for _, val := range t.Vals {
+				for _, ref := range varsPkg.References(val.Val) {
+					if defined[ref] || provided[ref] || (local[ref] && ref != t.Nam) || varsPkg.HasDefault(val.Val, ref) {
+						continue
+					}
+					if other, ok := declaredIn[ref]; ok && ref != t.Nam {
+						diags.Errorf(t.Line, "%s references %s which is declared in the later %s section", t.Nam, ref, other)
+						continue
+					}
+					diags.Errorf(t.Line, "%s references undefined var %s", t.Nam, ref)
+				}
+			}
+		}
+		if _, err := sec.ts.Sorted(definedVars(defined)); err != nil {
+			var d Diagnostic
+			if errors.As(err, &d) {
+				diags = append(diags, d)
+			}
+		}
+		for k := range local {
+			defined[k] = true
+		}
+	}
+	return diags
+}
+
+// Uses tells if a component of the file references the name without a
+// default.
+func (f File) Uses(name string) bool {
+	for _, ts := range []TypedComponents{f.Vars, f.Typ.Fields, f.After, f.Asserts} {
+		for _, t := range ts {
+			for _, val := range t.Vals {
+				if slices.Contains(varsPkg.References(val.Val), name) && !varsPkg.HasDefault(val.Val, name) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Sorted orders the components so that each one goes after the components
+// it references, keeping the file order otherwise. References to names
+// which are already set are not dependencies.
+func (ts TypedComponents) Sorted(vars varsPkg.Vars) (TypedComponents, error) {
+	deps := ts.deps(func(name string) bool {
+		_, ok := vars[name]
+		return ok
+	})
+	done := make([]bool, len(ts))
+	sorted := make(TypedComponents, 0, len(ts))
+	for len(sorted) < len(ts) {
+		progress := false
+		for i, v := range ts {
+			if done[i] || !allDone(deps[i], done) {
+				continue
+			}
+			done[i] = true
+			sorted = append(sorted, v)
+			progress = true
+			break
+		}
+		if !progress {
+			return nil, cycleError(ts, deps, done)
+		}
+	}
+	return sorted, nil
+}
+
+func allDone(deps []int, done []bool) bool {
+	for _, j := range deps {
+		if !done[j] {
+			return false
+		}
+	}
+	return true
+}
+
+func (ts TypedComponents) deps(isDefined func(string) bool) [][]int {
+	// a name declared twice has an edge to each declaration
+	index := map[string][]int{}
+	for i, v := range ts {
+		index[v.Nam] = append(index[v.Nam], i)
+	}
+	deps := make([][]int, len(ts))
+	for i, v := range ts {
+		for _, val := range v.Vals {
+			for _, ref := range varsPkg.References(val.Val) {
+				if isDefined(ref) {
+					continue
+				}
+				for _, j := range index[ref] {
+					if j != i {
+						deps[i] = append(deps[i], j)
+					}
+				}
+			}
+		}
+	}
+	return deps
+}
+
+func cycleError(ts TypedComponents, deps [][]int, done []bool) error {
+	// walk the remaining components until one repeats, that's the cycle
+	start := -1
+	for i := range ts {
+		if !done[i] {
+			start = i
+			break
+		}
+	}
+	path := []int{}
+	seen := map[int]int{}
+	cur := start
+	for {
+		if pos, ok := seen[cur]; ok {
+			path = path[pos:]
+			break
+		}
+		seen[cur] = len(path)
+		path = append(path, cur)
+		for _, j := range deps[cur] {
+			if !done[j] {
+				cur = j
+				break
+			}
+		}
+	}
+	names := []string{}
+	for _, i := range path {
+		names = append(names, ts[i].Nam)
+	}
+	names = append(names, ts[path[0]].Nam)
+	return Diagnostic{
+		Line:     ts[path[0]].Line,
+		Severity: SeverityError,
+		Msg:      "cycle between vars: " + strings.Join(names, " -> "),
+	}
+}
+
+func definedVars(defined map[string]bool) varsPkg.Vars {
+	vars := varsPkg.Vars{}
+	for k := range defined {
+		vars[k] = ""
+	}
+	return vars
+}
@@ -0,0 +1,145 @@
+package file
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
+)
+
+// text builds a text component of the given line.
+func text(line int, name, val string) TypedComponent {
+	return TypedComponent{Nam: name, Typ: TextType, Vals: []Value{{Val: val, Typ: TextType}}, Line: line}
+}
+
+func names(ts TypedComponents) []string {
+	res := []string{}
+	for _, t := range ts {
+		res = append(res, t.Nam)
+	}
+	return res
+}
+
+func TestSorted(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   TypedComponents
+		vars varsPkg.Vars
+		want []string
+	}{
+		{"file order", TypedComponents{text(1, "a", "1"), text(2, "b", "2")}, nil, []string{"a", "b"}},
+		{"dependencies first", TypedComponents{text(1, "c", "{{b}}"), text(2, "b", "{{a}}"), text(3, "a", "1")}, nil, []string{"a", "b", "c"}},
+		{"set vars aren't dependencies", TypedComponents{text(1, "b", "{{a}}"), text(2, "a", "1")}, varsPkg.Vars{"a": "0"}, []string{"b", "a"}},
+		{"undeclared references", TypedComponents{text(1, "a", "{{x}}"), text(2, "b", "1")}, nil, []string{"a", "b"}},
+		{"self reference", TypedComponents{text(1, "a", "{{a}}"), text(2, "b", "{{a}}")}, nil, []string{"a", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sorted, err := tt.ts.Sorted(tt.vars)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(sorted); !slices.Equal(got, tt.want) {
+				t.Errorf("Sorted() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortedCycles(t *testing.T) {
+	tests := []struct {
+		name string
+		ts   TypedComponents
+		want string
+	}{
+		{"two", TypedComponents{text(1, "a", "{{b}}"), text(2, "b", "{{a}}")}, "line 1: cycle between vars: a -> b -> a"},
+		{"three", TypedComponents{text(1, "x", "1"), text(2, "a", "{{c}}"), text(3, "b", "{{a}}"), text(4, "c", "{{b}}")}, "line 2: cycle between vars: a -> c -> b -> a"},
+		// the cycle goes through the first declaration of a
+		{"duplicate", TypedComponents{text(1, "a", "{{b}}"), text(2, "b", "{{a}}"), text(3, "a", "1")}, "cycle between vars: a -> b -> a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.ts.Sorted(nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Sorted() err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReferenceDiagnostics(t *testing.T) {
+	tests := []struct {
+		name string
+		f    File
+		vars varsPkg.Vars
+		want []string
+	}{
+		{
+			name: "declared",
+			f:    File{Vars: TypedComponents{text(1, "a", "1"), text(2, "b", "{{a}}")}, Typ: APIType{Fields: TypedComponents{text(3, "url", "{{b}}")}}},
+		},
+		{
+			name: "set",
+			f:    File{Typ: APIType{Fields: TypedComponents{text(3, "url", "{{host}}")}}},
+			vars: varsPkg.Vars{"host": "localhost"},
+		},
+		{
+			name: "default",
+			f:    File{Typ: APIType{Fields: TypedComponents{text(3, "url", `{{host | default "localhost"}}`)}}},
+		},
+		{
+			name: "undefined",
+			f:    File{Typ: APIType{Fields: TypedComponents{text(3, "url", "{{host}}")}}},
+			want: []string{"line 3: url references undefined var host"},
+		},
+		{
+			name: "provided",
+			f:    File{Provided: []string{"token"}, Typ: APIType{Fields: TypedComponents{text(3, "headers", "Authorization: {{token}}")}}},
+		},
+		{
+			name: "later section",
+			f:    File{Vars: TypedComponents{text(1, "a", "{{id}}")}, After: TypedComponents{text(5, "id", "$.id")}},
+			want: []string{"line 1: a references id which is declared in the later after section"},
+		},
+		{
+			name: "self reference",
+			f:    File{Vars: TypedComponents{text(1, "a", "{{a}}")}},
+			want: []string{"line 1: a references undefined var a"},
+		},
+		{
+			name: "self reference of a set var",
+			f:    File{Vars: TypedComponents{text(1, "a", "{{a}}")}},
+			vars: varsPkg.Vars{"a": "1"},
+		},
+		{
+			name: "cycle",
+			f:    File{Vars: TypedComponents{text(1, "a", "{{b}}"), text(2, "b", "{{a}}")}},
+			want: []string{"line 1: cycle between vars: a -> b -> a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, d := range tt.f.ReferenceDiagnostics(tt.vars) {
+				got = append(got, d.Error())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ReferenceDiagnostics() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUses(t *testing.T) {
+	f := File{
+		Vars:    TypedComponents{text(1, "a", `{{b | default "x"}}`)},
+		Typ:     APIType{Fields: TypedComponents{text(2, "url", "{{id}}")}},
+		Asserts: TypedComponents{text(3, "status", "{{code}}")},
+	}
+	for name, want := range map[string]bool{"id": true, "code": true, "b": false, "other": false} {
+		if got := f.Uses(name); got != want {
+			t.Errorf("Uses(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
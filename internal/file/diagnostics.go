@@ -0,0 +1,66 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+type (
+	Diagnostic struct {
+		Line     int
+		Severity string
+		Msg      string
+	}
+	Diagnostics []Diagnostic
+)
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("line %d: %s", d.Line, d.Msg)
+}
+
+func (ds *Diagnostics) Errorf(line int, format string, args ...any) {
+	*ds = append(*ds, Diagnostic{Line: line, Severity: SeverityError, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (ds *Diagnostics) Warnf(line int, format string, args ...any) {
+	*ds = append(*ds, Diagnostic{Line: line, Severity: SeverityWarning, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (ds Diagnostics) HasErrors() bool {
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err joins the error diagnostics, warnings are left out.
+func (ds Diagnostics) Err() error {
+	errs := []error{}
+	for _, d := range ds {
+		if d.Severity == SeverityError {
+			errs = append(errs, d)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Sorted orders the diagnostics by line and message.
+func (ds Diagnostics) Sorted() Diagnostics {
+	sorted := slices.Clone(ds)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Line != sorted[j].Line {
+			return sorted[i].Line < sorted[j].Line
+		}
+		return sorted[i].Msg < sorted[j].Msg
+	})
+	return sorted
+}
@@ -1,10 +1,17 @@
 package file
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/catmorte/go-mdapi/internal/command"
 	"github.com/catmorte/go-mdapi/internal/converters"
+	"github.com/catmorte/go-mdapi/internal/jsonpath"
+	"github.com/catmorte/go-mdapi/internal/secrets"
 	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
 )
 
@@ -13,17 +20,59 @@ const (
 	ListType       = "list"
 	ScriptType     = "script"
 	ScriptListType = "script_list"
+	JSONPathType   = "jsonpath"
+	HeaderType     = "header"
 )
 
+const (
+	SecretFlag   = "secret"
+	SessionFlag  = "session"
+	RequiredFlag = "required"
+)
+
+var flagsDescriptions = map[string]string{
+	SecretFlag:   "value is usable in the api but redacted in .vars, after files, compile output and errors (combine with script to read it from pass or a keyring cli)",
+	SessionFlag:  "after only, the value is saved to the session store of the directory and used as a var by the next runs",
+	RequiredFlag: "vars only, the value has to be set by --vars, an env or the session, with --interactive it's prompted and the declared value is the default",
+}
+
+func GetSupportedFlags() []string {
+	return []string{SecretFlag, SessionFlag, RequiredFlag}
+}
+
+func GetFlagDescription(key string) (string, error) {
+	d, ok := flagsDescriptions[key]
+	if !ok {
+		return "", fmt.Errorf("unknown flag %s", key)
+	}
+
+	return d, nil
+}
+
+func IsFlag(key string) bool {
+	_, ok := flagsDescriptions[key]
+	return ok
+}
+
 var typesDescriptions = map[string]string{
 	TextType:       "simple text type withing ``` ```",
 	ListType:       "one of the values in md list format (- value)",
 	ScriptType:     "same as text, but the content will be executed in sh",
 	ScriptListType: "same as text, but the content will be pre-executed in sh and each line will be treated as a list item",
+	JSONPathType:   "after only, json path ($.data.token) of the value in the response body",
+	HeaderType:     "after only, name of the response header to take the value of",
+}
+
+var nonNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// Slug turns a title into a name usable as a var, a request or a file name.
+func Slug(s string) string {
+	s = strings.Trim(nonNameChars.ReplaceAllString(strings.TrimSpace(s), "_"), "_")
+	return strings.ToLower(s)
 }
 
 func GetSupportedTypes() []string {
-	return []string{TextType, ListType, ScriptType, ScriptListType}
+	return []string{TextType, ListType, ScriptType, ScriptListType, JSONPathType, HeaderType}
 }
 
 func GetTypeDescription(key string) (string, error) {
@@ -37,31 +86,49 @@ func GetTypeDescription(key string) (string, error) {
 
 type (
 	File struct {
-		Dir   string
-		Nam   string
-		Vars  TypedComponents
-		Typ   APIType
-		After TypedComponents
+		Dir      string
+		Nam      string
+		Requires []string
+		Vars     TypedComponents
+		Typ      APIType
+		After    TypedComponents
+		Asserts  TypedComponents
+		// Provided are the after names of the requires and of the earlier
+		// requests, they count as declared until the run sets them.
+		Provided []string
 	}
 	APIType struct {
 		Typ    string
 		Fields TypedComponents
+		Line   int
 	}
 	TypedComponent struct {
 		Nam   string
 		Typ   string
 		Convs []string
+		Flags []string
 		Vals  []Value
+		Line  int
 	}
 	Value struct {
 		Val string
 		Typ string
 	}
 	TypedComponents []TypedComponent
+	// Ask returns the value of a var which isn't set, false means the
+	// declared value has to be used.
+	Ask func(t TypedComponent, vars varsPkg.Vars) (string, bool, error)
 )
 
-func (f File) Compute(vars varsPkg.Vars) (varsPkg.Vars, error) {
-	err := f.Vars.Compute(vars, false)
+var ErrRequired = errors.New("required var is not set")
+
+// Compute computes the vars which aren't set, ask is optional.
+func (f File) Compute(vars varsPkg.Vars, ask Ask) (varsPkg.Vars, error) {
+	err := f.CheckReferences(vars)
+	if err != nil {
+		return nil, err
+	}
+	err = f.Vars.compute(vars, false, ask)
 	if err != nil {
 		return nil, err
 	}
@@ -69,13 +136,37 @@ func (f File) Compute(vars varsPkg.Vars) (varsPkg.Vars, error) {
 }
 
 func (ts TypedComponents) Compute(vars varsPkg.Vars, forceCompute bool) error {
-	var err error
-	for _, v := range ts {
+	return ts.compute(vars, forceCompute, nil)
+}
+
+func (ts TypedComponents) compute(vars varsPkg.Vars, forceCompute bool, ask Ask) error {
+	preset := vars
+	if forceCompute {
+		preset = varsPkg.Vars{}
+	}
+	sorted, err := ts.Sorted(preset)
+	if err != nil {
+		return err
+	}
+	for _, v := range sorted {
 		val, ok := vars[v.Nam]
 		if !ok || forceCompute {
-			val, err = v.Compute(vars)
-			if err != nil {
-				return err
+			asked := false
+			switch {
+			case ok:
+			case ask != nil:
+				val, asked, err = ask(v, vars)
+				if err != nil {
+					return fmt.Errorf("%s: %w", v.Nam, err)
+				}
+			case v.HasFlag(RequiredFlag):
+				return fmt.Errorf("line %d: %s: %w", v.Line, v.Nam, ErrRequired)
+			}
+			if !asked {
+				val, err = v.Compute(vars)
+				if err != nil {
+					return err
+				}
 			}
 		} else {
 			err = v.Validate(val)
@@ -88,29 +179,87 @@ func (ts TypedComponents) Compute(vars varsPkg.Vars, forceCompute bool) error {
 		if err != nil {
 			return fmt.Errorf("failed to convert value %s: %w", val, err)
 		}
+		if v.HasFlag(SecretFlag) {
+			secrets.Add(val)
+		}
 		vars[v.Nam] = val
 	}
 	return nil
 }
 
 func (t TypedComponent) Compute(vars varsPkg.Vars) (string, error) {
+	if len(t.Vals) == 0 {
+		return "", fmt.Errorf("line %d: %s: no value declared", t.Line, t.Nam)
+	}
 	var val string
 	var err error
 	switch t.Typ {
 	case TextType:
 		fallthrough
 	case ListType:
-		val = varsPkg.ReplacePatterns(t.Vals[0].Val, vars)
+		val, err = varsPkg.Render(t.Vals[0].Val, vars)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", t.Nam, err)
+		}
 	case ScriptType:
-		val = varsPkg.ReplacePatterns(t.Vals[0].Val, vars)
+		val, err = varsPkg.Render(t.Vals[0].Val, vars)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", t.Nam, err)
+		}
 		val, err = command.RunCommand(val)
 		if err != nil {
-			return "", fmt.Errorf("failed to run command %s: %w", val, err)
+			return "", fmt.Errorf("failed to run command %s: %w", secrets.Redact(val), err)
+		}
+	case JSONPathType:
+		path, err := varsPkg.Render(t.Vals[0].Val, vars)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", t.Nam, err)
+		}
+		body, err := os.ReadFile(filepath.Join(vars.GetResultDir(), "body"))
+		if err != nil {
+			return "", fmt.Errorf("%s: failed to read response body: %w", t.Nam, err)
+		}
+		v, err := jsonpath.LookupBytes(body, path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", t.Nam, err)
+		}
+		val = jsonpath.String(v)
+	case HeaderType:
+		name, err := varsPkg.Render(t.Vals[0].Val, vars)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", t.Nam, err)
+		}
+		val, err = responseHeader(vars.GetResultDir(), strings.TrimSpace(name))
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", t.Nam, err)
 		}
 	}
 	return converters.Convert(val, t.Convs)
 }
 
+func responseHeader(resultDir, name string) (string, error) {
+	headers, err := os.ReadFile(filepath.Join(resultDir, "headers"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response headers: %w", err)
+	}
+	for _, line := range strings.Split(string(headers), "\n") {
+		key, val, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(key), name) {
+			return strings.TrimSpace(val), nil
+		}
+	}
+	return "", fmt.Errorf("header %s not found", name)
+}
+
+func (t TypedComponent) HasFlag(flag string) bool {
+	for _, v := range t.Flags {
+		if v == flag {
+			return true
+		}
+	}
+	return false
+}
+
 func (t TypedComponent) Validate(val string) error {
 	switch t.Typ {
 	case ListType:
@@ -122,7 +271,7 @@ func (t TypedComponent) Validate(val string) error {
 			}
 		}
 		if !found {
-			return fmt.Errorf("unknown value %s", val)
+			return fmt.Errorf("unknown value %s", secrets.Redact(val))
 		}
 	}
 	return nil
@@ -0,0 +1,236 @@
+package importer
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/file"
+)
+
+var ErrNotCurl = errors.New("not a curl command")
+
+func FromCurl(command string) (API, error) {
+	args, err := splitShell(command)
+	if err != nil {
+		return API{}, err
+	}
+	if len(args) == 0 || args[0] != "curl" {
+		return API{}, ErrNotCurl
+	}
+	api := API{}
+	data := []string{}
+	dataFile := ""
+	get := false
+	for i := 1; i < len(args); i++ {
+		arg := args[i]
+		next := func() (string, error) {
+			if i+1 >= len(args) {
+				return "", fmt.Errorf("missing value for %s", arg)
+			}
+			i++
+			return args[i], nil
+		}
+		name, inline, hasInline := strings.Cut(arg, "=")
+		if strings.HasPrefix(arg, "--") && hasInline {
+			arg = name
+			next = func() (string, error) {
+				return inline, nil
+			}
+		} else if len(arg) > 2 && arg[0] == '-' && arg[1] != '-' && strings.ContainsRune("XHdFuAbe", rune(arg[1])) {
+			value := arg[2:]
+			arg = arg[:2]
+			next = func() (string, error) {
+				return value, nil
+			}
+		}
+		switch arg {
+		case "-X", "--request":
+			api.Method, err = next()
+		case "-H", "--header":
+			var h string
+			h, err = next()
+			api.Headers = append(api.Headers, h)
+		case "-d", "--data", "--data-raw", "--data-ascii", "--data-binary", "--data-urlencode", "--json":
+			var d string
+			d, err = next()
+			if arg == "--json" {
+				api.Headers = append(api.Headers, "Content-Type: application/json", "Accept: application/json")
+			}
+			if strings.HasPrefix(d, "@") && arg != "--data-raw" {
+				dataFile = strings.TrimPrefix(d, "@")
+				continue
+			}
+			if arg == "--data-urlencode" {
+				d = urlencodeData(d)
+			}
+			data = append(data, d)
+		case "-F", "--form", "--form-string":
+			var f string
+			f, err = next()
+			api.Form = append(api.Form, formLine(f, arg == "--form-string"))
+		case "-u", "--user":
+			var u string
+			u, err = next()
+			api.Headers = append(api.Headers, "Authorization: Basic "+base64.StdEncoding.EncodeToString([]byte(u)))
+		case "-A", "--user-agent":
+			var a string
+			a, err = next()
+			api.Headers = append(api.Headers, "User-Agent: "+a)
+		case "-b", "--cookie":
+			var c string
+			c, err = next()
+			api.Headers = append(api.Headers, "Cookie: "+c)
+		case "-e", "--referer":
+			var r string
+			r, err = next()
+			api.Headers = append(api.Headers, "Referer: "+r)
+		case "-I", "--head":
+			api.Method = "HEAD"
+		case "-G", "--get":
+			get = true
+		case "--url":
+			api.URL, err = next()
+		case "-o", "--output", "-w", "--write-out", "--connect-timeout", "-m", "--max-time", "--cacert", "--cert", "--key", "-x", "--proxy", "--retry":
+			_, err = next()
+		default:
+			if strings.HasPrefix(arg, "-") {
+				continue
+			}
+			api.URL = arg
+		}
+		if err != nil {
+			return API{}, err
+		}
+	}
+	if api.URL == "" {
+		return API{}, errors.New("missing url in curl command")
+	}
+	body := strings.Join(data, "&")
+	switch {
+	case get && body != "":
+		sep := "?"
+		if strings.Contains(api.URL, "?") {
+			sep = "&"
+		}
+		api.URL += sep + body
+	case dataFile != "":
+		api.BodyFile = dataFile
+	default:
+		api.Body = body
+	}
+	if api.Method == "" {
+		api.Method = "GET"
+		if !get && (api.Body != "" || api.BodyFile != "" || len(api.Form) > 0) {
+			api.Method = "POST"
+		}
+	}
+	api.BodyType = bodyType(api.Headers, api.Body)
+	api.Nam = nameFromURL(api.Method, api.URL)
+	api.Path = file.Slug(api.Nam)
+	api.AddPlaceholderVars()
+	return api, nil
+}
+
+func formLine(f string, literal bool) string {
+	key, val, _ := strings.Cut(f, "=")
+	if !literal && strings.HasPrefix(val, "@") {
+		path, _, _ := strings.Cut(strings.TrimPrefix(val, "@"), ";")
+		return key + ": @" + path
+	}
+	if strings.HasPrefix(val, "@") {
+		val = "\\" + val
+	}
+	return key + ": " + val
+}
+
+func urlencodeData(d string) string {
+	key, val, ok := strings.Cut(d, "=")
+	if !ok {
+		return url.QueryEscape(d)
+	}
+	return key + "=" + url.QueryEscape(val)
+}
+
+func bodyType(headers []string, body string) string {
+	for _, h := range headers {
+		key, val, _ := strings.Cut(h, ":")
+		if strings.EqualFold(strings.TrimSpace(key), "Content-Type") && strings.Contains(val, "json") {
+			return "json"
+		}
+	}
+	trimmed := strings.TrimSpace(body)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		return "json"
+	}
+	return ""
+}
+
+func nameFromURL(method, rawURL string) string {
+	path := rawURL
+	if u, err := url.Parse(rawURL); err == nil && u.Path != "" {
+		path = u.Path
+	}
+	return strings.ToLower(method) + " " + strings.Trim(path, "/")
+}
+
+func splitShell(s string) ([]string, error) {
+	args := []string{}
+	sb := strings.Builder{}
+	inArg := false
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+				continue
+			}
+			sb.WriteByte(c)
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+				continue
+			}
+			if c == '\\' && i+1 < len(s) && strings.IndexByte("\"\\$`\n", s[i+1]) >= 0 {
+				i++
+				if s[i] != '\n' {
+					sb.WriteByte(s[i])
+				}
+				continue
+			}
+			sb.WriteByte(c)
+		case c == '\'' || c == '"':
+			quote = c
+			inArg = true
+		case c == '\\':
+			if i+1 < len(s) {
+				i++
+				if s[i] == '\n' || s[i] == '\r' {
+					continue
+				}
+				sb.WriteByte(s[i])
+				inArg = true
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inArg {
+				args = append(args, sb.String())
+				sb.Reset()
+				inArg = false
+			}
+		default:
+			sb.WriteByte(c)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unclosed quote in command")
+	}
+	if inArg {
+		args = append(args, sb.String())
+	}
+	return args, nil
+}
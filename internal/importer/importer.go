@@ -0,0 +1,189 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/file"
+)
+
+type (
+	Var struct {
+		Nam  string
+		Typ  string
+		Vals []string
+	}
+	API struct {
+		Path     string
+		Nam      string
+		Vars     []Var
+		Method   string
+		URL      string
+		Headers  []string
+		Body     string
+		BodyType string
+		BodyFile string
+		Form     []string
+	}
+)
+
+var (
+	nonNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+	placeholder  = regexp.MustCompile(`{{\s*([a-zA-Z0-9_]+)\s*}}`)
+)
+
+func Sources() []string {
+	return []string{"curl", "postman", "openapi"}
+}
+
+func Import(source string, raw []byte) ([]API, error) {
+	switch source {
+	case "curl":
+		api, err := FromCurl(string(raw))
+		if err != nil {
+			return nil, err
+		}
+		return []API{api}, nil
+	case "postman":
+		return FromPostman(raw)
+	case "openapi":
+		return FromOpenAPI(raw)
+	}
+	return nil, fmt.Errorf("unknown import source %s", source)
+}
+
+func (a *API) AddVar(v Var) {
+	v.Nam = strings.Trim(nonNameChars.ReplaceAllString(v.Nam, "_"), "_")
+	if v.Nam == "" {
+		return
+	}
+	for i, existing := range a.Vars {
+		if existing.Nam == v.Nam {
+			if len(existing.Vals) == 0 || (len(existing.Vals) == 1 && existing.Vals[0] == "") {
+				a.Vars[i] = v
+			}
+			return
+		}
+	}
+	a.Vars = append(a.Vars, v)
+}
+
+// AddPlaceholderVars declares an empty var for each {{name}} used in the api
+// that is not declared yet.
+func (a *API) AddPlaceholderVars() {
+	texts := append([]string{a.URL, a.Body, a.BodyFile}, a.Headers...)
+	texts = append(texts, a.Form...)
+	for _, t := range texts {
+		for _, m := range placeholder.FindAllStringSubmatch(t, -1) {
+			a.AddVar(Var{Nam: m[1], Typ: file.TextType, Vals: []string{""}})
+		}
+	}
+}
+
+func (a API) Markdown() string {
+	sb := strings.Builder{}
+	sb.WriteString("# " + a.Nam + "\n\n")
+	sb.WriteString("## vars\n")
+	for _, v := range a.Vars {
+		switch v.Typ {
+		case file.ListType:
+			sb.WriteString(fmt.Sprintf("\n### %s[%s]\n\n", v.Nam, file.ListType))
+			for _, val := range v.Vals {
+				sb.WriteString("- " + val + "\n")
+			}
+		default:
+			val := ""
+			if len(v.Vals) > 0 {
+				val = v.Vals[0]
+			}
+			sb.WriteString(fmt.Sprintf("\n### %s\n\n", v.Nam))
+			writeText(&sb, "", val)
+		}
+	}
+	sb.WriteString("\n## type[http]\n")
+	method := a.Method
+	if method == "" {
+		method = "GET"
+	}
+	sb.WriteString("\n### method\n\n")
+	writeText(&sb, "", strings.ToUpper(method))
+	sb.WriteString("\n### url\n\n")
+	writeText(&sb, "text", a.URL)
+	if len(a.Headers) > 0 {
+		sb.WriteString("\n### headers\n\n")
+		writeText(&sb, "", strings.Join(a.Headers, "\n"))
+	}
+	switch {
+	case a.BodyFile != "":
+		sb.WriteString("\n### bodyFile\n\n")
+		writeText(&sb, "", a.BodyFile)
+	case len(a.Form) > 0:
+		sb.WriteString("\n### form\n\n")
+		writeText(&sb, "", strings.Join(a.Form, "\n"))
+	case a.Body != "":
+		sb.WriteString("\n### body\n\n")
+		writeText(&sb, a.BodyType, a.Body)
+	}
+	sb.WriteString("\n## after\n")
+	return sb.String()
+}
+
+func writeText(sb *strings.Builder, typ, val string) {
+	fence := "```"
+	for strings.Contains(val, fence) {
+		fence += "`"
+	}
+	sb.WriteString(fence + typ + "\n")
+	sb.WriteString(val + "\n")
+	sb.WriteString(fence + "\n")
+}
+
+func Write(apis []API, outDir string) ([]string, error) {
+	written := []string{}
+	seen := map[string]int{}
+	for _, a := range apis {
+		path := a.Path
+		if path == "" {
+			path = file.Slug(a.Nam)
+		}
+		if path == "" {
+			path = "api"
+		}
+		seen[path]++
+		if seen[path] > 1 {
+			path = fmt.Sprintf("%s_%d", path, seen[path])
+		}
+		mdPath := filepath.Join(outDir, path+".md")
+		_, err := os.Stat(mdPath)
+		if err == nil {
+			return written, fmt.Errorf("file already exists: %s", mdPath)
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return written, err
+		}
+		err = os.MkdirAll(filepath.Dir(mdPath), 0o755)
+		if err != nil {
+			return written, err
+		}
+		err = os.WriteFile(mdPath, []byte(a.Markdown()), 0o644)
+		if err != nil {
+			return written, err
+		}
+		written = append(written, mdPath)
+	}
+	return written, nil
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,283 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/file"
+	"gopkg.in/yaml.v3"
+)
+
+var openAPIMethods = []string{"get", "post", "put", "patch", "delete", "head", "options", "trace"}
+
+type openAPISpec struct {
+	root map[string]any
+}
+
+func FromOpenAPI(raw []byte) ([]API, error) {
+	root := map[string]any{}
+	err := yaml.Unmarshal(raw, &root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode openapi spec: %w", err)
+	}
+	if _, ok := root["openapi"]; !ok {
+		return nil, errors.New("not an openapi 3 spec: missing openapi field")
+	}
+	spec := openAPISpec{root: root}
+	paths, _ := root["paths"].(map[string]any)
+	if len(paths) == 0 {
+		return nil, errors.New("openapi spec has no paths")
+	}
+	baseURL := spec.baseURL()
+	apis := []API{}
+	for _, p := range sortedKeys(paths) {
+		pathItem, _ := spec.resolve(paths[p]).(map[string]any)
+		for _, method := range openAPIMethods {
+			op, ok := pathItem[method].(map[string]any)
+			if !ok {
+				continue
+			}
+			apis = append(apis, spec.operation(p, method, pathItem, op, baseURL))
+		}
+	}
+	return apis, nil
+}
+
+func (s openAPISpec) baseURL() string {
+	servers, _ := s.root["servers"].([]any)
+	if len(servers) == 0 {
+		return "http://localhost"
+	}
+	server, _ := servers[0].(map[string]any)
+	u, _ := server["url"].(string)
+	vars, _ := server["variables"].(map[string]any)
+	for k, v := range vars {
+		vm, _ := v.(map[string]any)
+		u = strings.ReplaceAll(u, "{"+k+"}", fmt.Sprint(vm["default"]))
+	}
+	return strings.TrimSuffix(u, "/")
+}
+
+func (s openAPISpec) operation(p, method string, pathItem, op map[string]any, baseURL string) API {
+	api := API{Method: strings.ToUpper(method)}
+	api.AddVar(Var{Nam: "baseUrl", Typ: file.TextType, Vals: []string{baseURL}})
+
+	opID, _ := op["operationId"].(string)
+	summary, _ := op["summary"].(string)
+	api.Nam = summary
+	if api.Nam == "" {
+		api.Nam = opID
+	}
+	if api.Nam == "" {
+		api.Nam = method + " " + p
+	}
+	fileName := file.Slug(opID)
+	if fileName == "" {
+		fileName = file.Slug(method + " " + p)
+	}
+	dir := ""
+	if tags, ok := op["tags"].([]any); ok && len(tags) > 0 {
+		dir = file.Slug(fmt.Sprint(tags[0]))
+	}
+	api.Path = path.Join(dir, fileName)
+
+	params := []any{}
+	if v, ok := pathItem["parameters"].([]any); ok {
+		params = append(params, v...)
+	}
+	if v, ok := op["parameters"].([]any); ok {
+		params = append(params, v...)
+	}
+	urlPath := p
+	query := []string{}
+	for _, rawParam := range params {
+		param, _ := s.resolve(rawParam).(map[string]any)
+		name, _ := param["name"].(string)
+		in, _ := param["in"].(string)
+		if name == "" {
+			continue
+		}
+		v := s.paramVar(name, param)
+		required, _ := param["required"].(bool)
+		if in != "path" && !required && (len(v.Vals) == 0 || v.Vals[0] == "") {
+			continue
+		}
+		switch in {
+		case "path":
+			urlPath = strings.ReplaceAll(urlPath, "{"+name+"}", "{{"+v.Nam+"}}")
+		case "query":
+			query = append(query, name+"={{"+v.Nam+"}}")
+		case "header":
+			api.Headers = append(api.Headers, name+": {{"+v.Nam+"}}")
+		default:
+			continue
+		}
+		api.AddVar(v)
+	}
+	api.URL = "{{baseUrl}}" + urlPath
+	if len(query) > 0 {
+		api.URL += "?" + strings.Join(query, "&")
+	}
+	s.requestBody(&api, op)
+	api.AddPlaceholderVars()
+	return api
+}
+
+func (s openAPISpec) paramVar(name string, param map[string]any) Var {
+	v := Var{Nam: strings.Trim(nonNameChars.ReplaceAllString(name, "_"), "_"), Typ: file.TextType}
+	schema, _ := s.resolve(param["schema"]).(map[string]any)
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		v.Typ = file.ListType
+		for _, e := range enum {
+			v.Vals = append(v.Vals, fmt.Sprint(e))
+		}
+		return v
+	}
+	for _, src := range []any{param["example"], schema["example"], schema["default"]} {
+		if src != nil {
+			v.Vals = []string{fmt.Sprint(src)}
+			return v
+		}
+	}
+	v.Vals = []string{""}
+	return v
+}
+
+func (s openAPISpec) requestBody(api *API, op map[string]any) {
+	body, _ := s.resolve(op["requestBody"]).(map[string]any)
+	content, _ := body["content"].(map[string]any)
+	if len(content) == 0 {
+		return
+	}
+	for _, ct := range []string{"application/json", "multipart/form-data", "application/x-www-form-urlencoded"} {
+		media, ok := content[ct].(map[string]any)
+		if !ok {
+			continue
+		}
+		schema, _ := s.resolve(media["schema"]).(map[string]any)
+		switch ct {
+		case "application/json":
+			example := s.mediaExample(media, schema)
+			raw, err := json.MarshalIndent(example, "", "  ")
+			if err == nil {
+				api.Body = string(raw)
+				api.BodyType = "json"
+			}
+			api.Headers = append(api.Headers, "Content-Type: application/json")
+		case "multipart/form-data":
+			props, _ := schema["properties"].(map[string]any)
+			for _, k := range sortedKeys(props) {
+				prop, _ := s.resolve(props[k]).(map[string]any)
+				name := strings.Trim(nonNameChars.ReplaceAllString(k, "_"), "_")
+				if format, _ := prop["format"].(string); format == "binary" {
+					api.Form = append(api.Form, k+": @{{"+name+"}}")
+					continue
+				}
+				api.Form = append(api.Form, k+": {{"+name+"}}")
+			}
+		case "application/x-www-form-urlencoded":
+			props, _ := schema["properties"].(map[string]any)
+			params := []string{}
+			for _, k := range sortedKeys(props) {
+				params = append(params, k+"={{"+strings.Trim(nonNameChars.ReplaceAllString(k, "_"), "_")+"}}")
+			}
+			api.Body = strings.Join(params, "&")
+			api.Headers = append(api.Headers, "Content-Type: application/x-www-form-urlencoded")
+		}
+		return
+	}
+}
+
+func (s openAPISpec) mediaExample(media, schema map[string]any) any {
+	if example, ok := media["example"]; ok {
+		return example
+	}
+	if examples, ok := media["examples"].(map[string]any); ok {
+		for _, k := range sortedKeys(examples) {
+			example, _ := s.resolve(examples[k]).(map[string]any)
+			if v, ok := example["value"]; ok {
+				return v
+			}
+		}
+	}
+	return s.schemaExample(schema, 0)
+}
+
+func (s openAPISpec) schemaExample(schema map[string]any, depth int) any {
+	if schema == nil || depth > 8 {
+		return nil
+	}
+	for _, k := range []string{"example", "default"} {
+		if v, ok := schema[k]; ok {
+			return v
+		}
+	}
+	if enum, ok := schema["enum"].([]any); ok && len(enum) > 0 {
+		return enum[0]
+	}
+	for _, k := range []string{"allOf", "oneOf", "anyOf"} {
+		if subs, ok := schema[k].([]any); ok && len(subs) > 0 {
+			if k != "allOf" {
+				sub, _ := s.resolve(subs[0]).(map[string]any)
+				return s.schemaExample(sub, depth+1)
+			}
+			merged := map[string]any{}
+			for _, rawSub := range subs {
+				sub, _ := s.resolve(rawSub).(map[string]any)
+				if m, ok := s.schemaExample(sub, depth+1).(map[string]any); ok {
+					for mk, mv := range m {
+						merged[mk] = mv
+					}
+				}
+			}
+			return merged
+		}
+	}
+	typ, _ := schema["type"].(string)
+	switch typ {
+	case "object", "":
+		props, ok := schema["properties"].(map[string]any)
+		if !ok && typ == "" {
+			return nil
+		}
+		res := map[string]any{}
+		for _, k := range sortedKeys(props) {
+			prop, _ := s.resolve(props[k]).(map[string]any)
+			res[k] = s.schemaExample(prop, depth+1)
+		}
+		return res
+	case "array":
+		items, _ := s.resolve(schema["items"]).(map[string]any)
+		return []any{s.schemaExample(items, depth+1)}
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	default:
+		return ""
+	}
+}
+
+func (s openAPISpec) resolve(v any) any {
+	for i := 0; i < 16; i++ {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return v
+		}
+		ref, ok := m["$ref"].(string)
+		if !ok || !strings.HasPrefix(ref, "#/") {
+			return v
+		}
+		var cur any = s.root
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+			cm, _ := cur.(map[string]any)
+			cur = cm[part]
+		}
+		v = cur
+	}
+	return v
+}
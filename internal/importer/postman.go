@@ -0,0 +1,176 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/file"
+)
+
+type (
+	postmanCollection struct {
+		Info struct {
+			Name string `json:"name"`
+		} `json:"info"`
+		Item     []postmanItem     `json:"item"`
+		Variable []postmanKeyValue `json:"variable"`
+	}
+	postmanItem struct {
+		Name    string          `json:"name"`
+		Item    []postmanItem   `json:"item"`
+		Request *postmanRequest `json:"request"`
+	}
+	postmanRequest struct {
+		Method string            `json:"method"`
+		Header []postmanKeyValue `json:"header"`
+		URL    postmanURL        `json:"url"`
+		Body   *postmanBody      `json:"body"`
+	}
+	postmanURL struct {
+		Raw string `json:"raw"`
+	}
+	postmanBody struct {
+		Mode       string            `json:"mode"`
+		Raw        string            `json:"raw"`
+		URLEncoded []postmanKeyValue `json:"urlencoded"`
+		FormData   []postmanKeyValue `json:"formdata"`
+		File       struct {
+			Src string `json:"src"`
+		} `json:"file"`
+		Options struct {
+			Raw struct {
+				Language string `json:"language"`
+			} `json:"raw"`
+		} `json:"options"`
+	}
+	postmanKeyValue struct {
+		Key      string `json:"key"`
+		Value    any    `json:"value"`
+		Type     string `json:"type"`
+		Src      any    `json:"src"`
+		Disabled bool   `json:"disabled"`
+	}
+)
+
+func (u *postmanURL) UnmarshalJSON(raw []byte) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		u.Raw = s
+		return nil
+	}
+	type plain postmanURL
+	return json.Unmarshal(raw, (*plain)(u))
+}
+
+func (kv postmanKeyValue) value() string {
+	if kv.Value == nil {
+		return ""
+	}
+	if s, ok := kv.Value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(kv.Value)
+}
+
+func FromPostman(raw []byte) ([]API, error) {
+	c := postmanCollection{}
+	err := json.Unmarshal(raw, &c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode postman collection: %w", err)
+	}
+	if len(c.Item) == 0 {
+		return nil, errors.New("postman collection has no items")
+	}
+	vars := []Var{}
+	for _, v := range c.Variable {
+		vars = append(vars, Var{Nam: v.Key, Typ: file.TextType, Vals: []string{v.value()}})
+	}
+	apis := []API{}
+	for _, item := range c.Item {
+		apis = append(apis, postmanItems(item, "", vars)...)
+	}
+	return apis, nil
+}
+
+func postmanItems(item postmanItem, dir string, vars []Var) []API {
+	if item.Request == nil {
+		apis := []API{}
+		for _, sub := range item.Item {
+			apis = append(apis, postmanItems(sub, path.Join(dir, file.Slug(item.Name)), vars)...)
+		}
+		return apis
+	}
+	rq := item.Request
+	api := API{
+		Nam:    item.Name,
+		Path:   path.Join(dir, file.Slug(item.Name)),
+		Method: rq.Method,
+		URL:    rq.URL.Raw,
+	}
+	for _, h := range rq.Header {
+		if h.Disabled {
+			continue
+		}
+		api.Headers = append(api.Headers, h.Key+": "+h.value())
+	}
+	if rq.Body != nil {
+		switch rq.Body.Mode {
+		case "raw":
+			api.Body = rq.Body.Raw
+			api.BodyType = rq.Body.Options.Raw.Language
+			if api.BodyType == "" {
+				api.BodyType = bodyType(api.Headers, api.Body)
+			}
+		case "urlencoded":
+			params := []string{}
+			for _, kv := range rq.Body.URLEncoded {
+				if kv.Disabled {
+					continue
+				}
+				params = append(params, urlencodeData(kv.Key+"="+kv.value()))
+			}
+			api.Body = strings.Join(params, "&")
+			api.Headers = append(api.Headers, "Content-Type: application/x-www-form-urlencoded")
+		case "formdata":
+			for _, kv := range rq.Body.FormData {
+				if kv.Disabled {
+					continue
+				}
+				if kv.Type == "file" {
+					src := fmt.Sprint(kv.Src)
+					if srcs, ok := kv.Src.([]any); ok && len(srcs) > 0 {
+						src = fmt.Sprint(srcs[0])
+					}
+					api.Form = append(api.Form, kv.Key+": @"+src)
+					continue
+				}
+				api.Form = append(api.Form, formLine(kv.Key+"="+kv.value(), true))
+			}
+		case "file":
+			api.BodyFile = rq.Body.File.Src
+		}
+	}
+	for _, v := range vars {
+		if usesVar(api, v.Nam) {
+			api.AddVar(v)
+		}
+	}
+	api.AddPlaceholderVars()
+	return []API{api}
+}
+
+func usesVar(api API, name string) bool {
+	texts := append([]string{api.URL, api.Body, api.BodyFile}, api.Headers...)
+	texts = append(texts, api.Form...)
+	for _, t := range texts {
+		for _, m := range placeholder.FindAllStringSubmatch(t, -1) {
+			if m[1] == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrNotFound = errors.New("path not found")
+
+// Lookup walks decoded json data by a path like $.data.items[0].id,
+// data.items.0.id or $["some key"].
+func Lookup(data any, path string) (any, error) {
+	keys, err := split(path)
+	if err != nil {
+		return nil, err
+	}
+	cur := data
+	for i, key := range keys {
+		switch node := cur.(type) {
+		case map[string]any:
+			v, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrNotFound, join(keys[:i+1]))
+			}
+			cur = v
+		case []any:
+			index, err := strconv.Atoi(key)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s is not an array index", ErrNotFound, join(keys[:i+1]))
+			}
+			if index < 0 {
+				index += len(node)
+			}
+			if index < 0 || index >= len(node) {
+				return nil, fmt.Errorf("%w: %s is out of bounds", ErrNotFound, join(keys[:i+1]))
+			}
+			cur = node[index]
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, join(keys[:i+1]))
+		}
+	}
+	return cur, nil
+}
+
+func LookupBytes(raw []byte, path string) (any, error) {
+	var data any
+	err := json.Unmarshal(raw, &data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode json: %w", err)
+	}
+	return Lookup(data, path)
+}
+
+func String(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(raw)
+}
+
+func split(path string) ([]string, error) {
+	path = strings.TrimSpace(path)
+	path = strings.TrimPrefix(path, "$")
+	keys := []string{}
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed bracket in path %s", path)
+			}
+			key := path[i+1 : i+end]
+			if unquoted, err := strconv.Unquote(key); err == nil {
+				key = unquoted
+			} else if len(key) > 1 && key[0] == '\'' && key[len(key)-1] == '\'' {
+				key = key[1 : len(key)-1]
+			}
+			keys = append(keys, key)
+			i += end + 1
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			keys = append(keys, path[i:i+end])
+			i += end
+		}
+	}
+	return keys, nil
+}
+
+func join(keys []string) string {
+	sb := strings.Builder{}
+	sb.WriteString("$")
+	for _, k := range keys {
+		sb.WriteString(".")
+		sb.WriteString(k)
+	}
+	return sb.String()
+}
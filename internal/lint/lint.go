@@ -0,0 +1,121 @@
+package lint
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/file"
+	"github.com/catmorte/go-mdapi/internal/parser"
+	"github.com/catmorte/go-mdapi/internal/types"
+	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
+)
+
+// Lint reports the parser diagnostics together with unknown types and
+// fields, duplicate and unused vars and the references which can't be
+// resolved with the given vars. The requests of a file are checked in order,
+// so a request can reference the after values of the previous ones.
+func Lint(mdPath string, vars varsPkg.Vars, dts types.DefinedTypes) (file.Diagnostics, error) {
+	fs, diags, err := parser.Parse(mdPath)
+	if err != nil {
+		return nil, err
+	}
+
+	vars = maps.Clone(vars)
+	if vars == nil {
+		vars = varsPkg.Vars{}
+	}
+	// shared vars are in every request, so they are unused only if no
+	// request uses them
+	declared := map[int]int{}
+	unusedIn := map[int]int{}
+	names := map[int]string{}
+	for _, f := range fs {
+		typeVars := []string{}
+		switch {
+		case f.Typ.Line == 0:
+			diags.Errorf(1, "missing ## type[name] section")
+		case f.Typ.Typ != "":
+			dt, err := dts.FindByName(f.Typ.Typ)
+			if err != nil {
+				diags.Errorf(f.Typ.Line, "unknown type %s", f.Typ.Typ)
+				break
+			}
+			for _, v := range dt.GetVars() {
+				typeVars = append(typeVars, strings.TrimSpace(v))
+			}
+			for _, t := range f.Typ.Fields {
+				if !slices.Contains(typeVars, t.Nam) {
+					diags.Errorf(t.Line, "%s is not a field of type %s", t.Nam, f.Typ.Typ)
+				}
+			}
+		}
+
+		for _, ts := range []file.TypedComponents{f.Vars, f.Typ.Fields, f.After, f.Asserts} {
+			duplicates(ts, &diags)
+		}
+		for _, t := range f.Vars {
+			declared[t.Line]++
+			names[t.Line] = t.Nam
+		}
+		for _, t := range unused(f, typeVars) {
+			unusedIn[t.Line]++
+		}
+		// the requires are run before the request, their after values
+		// are set like the ones of the earlier requests
+		provided, err := parser.Provided(mdPath, f)
+		if err != nil {
+			diags.Errorf(1, "%s", err)
+		}
+		reqVars := maps.Clone(vars)
+		for _, k := range provided {
+			reqVars[k] = ""
+		}
+		diags = append(diags, f.ReferenceDiagnostics(reqVars)...)
+		for _, t := range f.After {
+			vars[t.Nam] = ""
+		}
+	}
+	for line, n := range unusedIn {
+		if n == declared[line] {
+			diags.Warnf(line, "%s is never used", names[line])
+		}
+	}
+	diags = slices.CompactFunc(diags.Sorted(), func(a, b file.Diagnostic) bool {
+		return a == b
+	})
+	return diags, nil
+}
+
+func duplicates(ts file.TypedComponents, diags *file.Diagnostics) {
+	seen := map[string]int{}
+	for _, t := range ts {
+		if line, ok := seen[t.Nam]; ok {
+			diags.Errorf(t.Line, "%s is already declared at line %d", t.Nam, line)
+			continue
+		}
+		seen[t.Nam] = t.Line
+	}
+}
+
+func unused(f *file.File, typeVars []string) []file.TypedComponent {
+	used := map[string]bool{}
+	for _, ts := range []file.TypedComponents{f.Vars, f.Typ.Fields, f.After, f.Asserts} {
+		for _, t := range ts {
+			for _, v := range t.Vals {
+				for _, ref := range varsPkg.References(v.Val) {
+					if ref != t.Nam {
+						used[ref] = true
+					}
+				}
+			}
+		}
+	}
+	res := []file.TypedComponent{}
+	for _, t := range f.Vars {
+		if !used[t.Nam] && !slices.Contains(typeVars, t.Nam) {
+			res = append(res, t)
+		}
+	}
+	return res
+}
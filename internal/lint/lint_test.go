@@ -0,0 +1,129 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/catmorte/go-mdapi/internal/types"
+	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
+)
+
+const fence = "```"
+
+func code(v string) string {
+	return fence + "\n" + v + "\n" + fence + "\n\n"
+}
+
+func lint(t *testing.T, files map[string]string, name string, vars varsPkg.Vars) []string {
+	t.Helper()
+	dir := t.TempDir()
+	for n, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	diags, err := Lint(filepath.Join(dir, name), vars, types.InternalTypes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := []string{}
+	for _, d := range diags {
+		res = append(res, d.Severity+": "+d.Error())
+	}
+	return res
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		vars varsPkg.Vars
+		want []string
+	}{
+		{
+			name: "clean",
+			src:  "## vars\n\n### host\n\n" + code("localhost") + "## type[http]\n\n### url\n\n" + code("http://{{host}}"),
+		},
+		{
+			name: "missing type",
+			src:  "## vars\n\n### host\n\n" + code("localhost"),
+			want: []string{"error: line 1: missing ## type[name] section", "warning: line 3: host is never used"},
+		},
+		{
+			name: "unknown type",
+			src:  "## type[nope]\n\n### url\n\n" + code("x"),
+			want: []string{"error: line 1: unknown type nope"},
+		},
+		{
+			name: "unknown field",
+			src:  "## type[http]\n\n### url\n\n" + code("x") + "### nope\n\n" + code("x"),
+			want: []string{"error: line 9: nope is not a field of type http"},
+		},
+		{
+			name: "duplicate var",
+			src:  "## vars\n\n### a\n\n" + code("1") + "### a\n\n" + code("2") + "## type[http]\n\n### url\n\n" + code("{{a}}"),
+			want: []string{"error: line 9: a is already declared at line 3"},
+		},
+		{
+			name: "undefined",
+			src:  "## type[http]\n\n### url\n\n" + code("{{host}}"),
+			want: []string{"error: line 3: url references undefined var host"},
+		},
+		{
+			name: "set from the cli",
+			src:  "## type[http]\n\n### url\n\n" + code("{{host}}"),
+			vars: varsPkg.Vars{"host": "localhost"},
+		},
+		{
+			name: "cycle",
+			src:  "## vars\n\n### a\n\n" + code("{{b}}") + "### b\n\n" + code("{{a}}") + "## type[http]\n\n### url\n\n" + code("{{a}}"),
+			want: []string{"error: line 3: cycle between vars: a -> b -> a"},
+		},
+		{
+			name: "empty list",
+			src:  "## vars\n\n### env[list]\n\n## type[http]\n\n### url\n\n" + code("{{env}}"),
+			want: []string{"error: line 3: env is a list without items"},
+		},
+		{
+			name: "blockquoted fence",
+			src:  "> note\n>\n> " + fence + "\n> {{x}}\n> " + fence + "\n\n## type[http]\n\n### url\n\n" + code("x"),
+		},
+		{
+			name: "earlier request after",
+			src: "# create\n\n## type[http]\n\n### url\n\n" + code("x") + "## after\n\n### id[jsonpath]\n\n" + code("$.id") +
+				"# get\n\n## type[http]\n\n### url\n\n" + code("x/{{id}}"),
+		},
+		{
+			name: "later request after",
+			src: "# get\n\n## type[http]\n\n### url\n\n" + code("x/{{id}}") +
+				"# create\n\n## type[http]\n\n### url\n\n" + code("x") + "## after\n\n### id[jsonpath]\n\n" + code("$.id"),
+			want: []string{"error: line 5: url references undefined var id"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lint(t, map[string]string{"api.md": tt.src}, "api.md", tt.vars)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Lint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLintRequires(t *testing.T) {
+	files := map[string]string{
+		"login.md":  "## type[http]\n\n### url\n\n" + code("x") + "## after\n\n### token[jsonpath]\n\n" + code("$.token"),
+		"use.md":    "## requires\n\n- login.md\n\n## type[http]\n\n### headers\n\n" + code("Authorization: {{token}}") + "### url\n\n" + code("x"),
+		"broken.md": "## requires\n\n- missing.md\n\n## type[http]\n\n### url\n\n" + code("x"),
+	}
+	if got := lint(t, files, "use.md", nil); len(got) != 0 {
+		t.Errorf("Lint(use.md) = %q, expected the after of the require to be defined", got)
+	}
+	got := lint(t, files, "broken.md", nil)
+	if len(got) != 1 || !strings.HasPrefix(got[0], "error: line 1: failed to read required missing.md") {
+		t.Errorf("Lint(broken.md) = %q", got)
+	}
+}
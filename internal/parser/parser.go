@@ -1,38 +1,46 @@
 package parser
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
 
+	"github.com/catmorte/go-mdapi/internal/assertions"
 	"github.com/catmorte/go-mdapi/internal/command"
+	"github.com/catmorte/go-mdapi/internal/converters"
 	"github.com/catmorte/go-mdapi/internal/file"
+	"github.com/catmorte/go-mdapi/internal/secrets"
 	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
 )
 
 func ParseMarkdownFile(mdPath string, vars varsPkg.Vars) (*file.File, error) {
-	bytes, err := os.ReadFile(mdPath)
+	return ParseRequest(mdPath, "", vars)
+}
+
+// ParseRequest parses the request with the given name, the name can be
+// omitted when the file declares a single request.
+func ParseRequest(mdPath, name string, vars varsPkg.Vars) (*file.File, error) {
+	fs, diags, err := Parse(mdPath)
 	if err != nil {
 		return nil, err
 	}
-	s := string(bytes)
-	lines := strings.Split(s, "\n")
-	f := file.File{}
-	for i := 0; i < len(lines); i++ {
-		if lines[i] == "## vars" {
-			skip, vars := parseVars(lines[i:])
-			i += skip
-			f.Vars = vars
-		} else if lines[i] == "## after" {
-			skip, after := parseVars(lines[i+1:])
-			i += skip
-			f.After = after
-		} else if strings.HasPrefix(lines[i], "## type") {
-			skip, typ := parseType(lines[i:])
-			i += skip
-			f.Typ = typ
-		}
+	err = diags.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", mdPath, err)
+	}
+	f, err := selectRequest(mdPath, name, fs)
+	if err != nil {
+		return nil, err
+	}
+	f.Provided, err = Provided(mdPath, f)
+	if err != nil {
+		return nil, err
 	}
 
 	f.Vars, err = fileListReplacement(f.Vars, vars)
@@ -45,180 +53,451 @@ func ParseMarkdownFile(mdPath string, vars varsPkg.Vars) (*file.File, error) {
 		return nil, err
 	}
 
-	return &f, nil
+	return f, nil
 }
 
-func fileListReplacement(ts file.TypedComponents, vars varsPkg.Vars) (file.TypedComponents, error) {
-	newTs := make(file.TypedComponents, 0, len(ts))
-	for _, t := range ts {
-		switch t.Typ {
-		case file.ScriptListType:
-			val := varsPkg.ReplacePatterns(t.Vals[0].Val, vars)
-			val, err := command.RunCommand(val)
-			if err != nil {
-				return nil, fmt.Errorf("failed to run command %s: %w", val, err)
-			}
-			values := strings.Split(val, "\n")
+func selectRequest(mdPath, name string, fs []*file.File) (*file.File, error) {
+	names := []string{}
+	for _, f := range fs {
+		if f.Nam == name {
+			return f, nil
+		}
+		names = append(names, f.Nam)
+	}
+	if name == "" {
+		return nil, fmt.Errorf("%s declares several requests, select one of: %s", mdPath, strings.Join(names, ", "))
+	}
+	return nil, fmt.Errorf("no request %s in %s", name, mdPath)
+}
 
-			newVals := make([]file.Value, 0, len(values))
-			for _, v := range values {
-				newVals = append(newVals, file.Value{
-					Val: v,
-					Typ: file.TextType,
-				})
-			}
+// RequestNames returns the names of the requests in the declaration order,
+// it's empty when the file declares a single request.
+func RequestNames(mdPath string) ([]string, error) {
+	fs, _, err := Parse(mdPath)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, f := range fs {
+		if f.Nam != "" {
+			names = append(names, f.Nam)
+		}
+	}
+	return names, nil
+}
 
-			newT := file.TypedComponent{
-				Nam:   t.Nam,
-				Typ:   file.ListType,
-				Convs: t.Convs,
-				Vals:  newVals,
-			}
+// Parse reads the file as is (script lists aren't run) and collects
+// diagnostics for the malformed content. A file with several top level
+// headings having their own type section declares several requests, each
+// named after its heading, which share the sections of the other headings
+// and the ones above the first heading.
+// Otherwise the whole file is a single request with an empty name.
+func Parse(mdPath string) ([]*file.File, file.Diagnostics, error) {
+	src, err := os.ReadFile(mdPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	fs, diags := parse(src)
+	return fs, diags, nil
+}
 
-			newTs = append(newTs, newT)
-		default:
-			newTs = append(newTs, t)
+func IsAPIFile(mdPath string) (bool, error) {
+	fs, _, err := Parse(mdPath)
+	if err != nil {
+		return false, err
+	}
+	return fs[0].Typ.Line > 0, nil
+}
+
+type mdParser struct {
+	src   []byte
+	diags file.Diagnostics
+}
+
+type request struct {
+	heading *ast.Heading
+	nodes   []ast.Node
+}
+
+func parse(src []byte) ([]*file.File, file.Diagnostics) {
+	src = bytes.ReplaceAll(src, []byte("\r\n"), []byte("\n"))
+	p := &mdParser{src: src}
+	doc := goldmark.DefaultParser().Parse(text.NewReader(src))
+	p.checkFences(doc)
+
+	all := []ast.Node{}
+	shared := []ast.Node{}
+	requests := []request{}
+	for n := doc.FirstChild(); n != nil; n = n.NextSibling() {
+		all = append(all, n)
+		if h, ok := n.(*ast.Heading); ok && h.Level == 1 {
+			requests = append(requests, request{heading: h})
+			continue
+		}
+		if len(requests) == 0 {
+			shared = append(shared, n)
 			continue
 		}
+		requests[len(requests)-1].nodes = append(requests[len(requests)-1].nodes, n)
+	}
 
+	typed := 0
+	for _, r := range requests {
+		if p.hasType(r.nodes) {
+			typed++
+		}
 	}
-	return newTs, nil
+	if typed < 2 {
+		return []*file.File{p.sections(all)}, p.diags
+	}
+
+	for _, r := range requests {
+		if !p.hasType(r.nodes) {
+			shared = append(shared, r.nodes...)
+		}
+	}
+	common := p.sections(shared)
+	if common.Typ.Line > 0 {
+		p.diags.Errorf(common.Typ.Line, "type section outside of a request heading")
+	}
+	fs := []*file.File{}
+	seen := map[string]int{}
+	for _, r := range requests {
+		if !p.hasType(r.nodes) {
+			continue
+		}
+		line := p.line(r.heading)
+		f := p.sections(r.nodes)
+		f.Nam = file.Slug(p.text(r.heading))
+		if first, ok := seen[f.Nam]; ok {
+			p.diags.Errorf(line, "duplicate request %s, first declared at line %d", f.Nam, first)
+		}
+		seen[f.Nam] = line
+		fs = append(fs, share(f, common))
+	}
+	return fs, p.diags
 }
 
-func isSection(s string) bool {
-	return strings.HasPrefix(s, "## ")
+func (p *mdParser) hasType(nodes []ast.Node) bool {
+	for _, n := range nodes {
+		if h, ok := n.(*ast.Heading); ok && h.Level == 2 && strings.HasPrefix(p.text(h), "type") {
+			return true
+		}
+	}
+	return false
 }
 
-func isSubSection(s string) bool {
-	return strings.HasPrefix(s, "### ")
+// share prepends the shared sections, the request vars win over the shared
+// ones with the same name.
+func share(f, common *file.File) *file.File {
+	vars := file.TypedComponents{}
+	for _, v := range common.Vars {
+		if _, ok := f.GetVarByName(v.Nam); !ok {
+			vars = append(vars, v)
+		}
+	}
+	f.Vars = append(vars, f.Vars...)
+	f.Requires = append(slices.Clone(common.Requires), f.Requires...)
+	f.After = append(slices.Clone(common.After), f.After...)
+	f.Asserts = append(slices.Clone(common.Asserts), f.Asserts...)
+	return f
 }
 
-func parseType(s []string) (int, file.APIType) {
-	r := regexp.MustCompile(`## (?P<name>[a-zA-Z0-9_]+)\[(?P<type>[a-zA-Z0-9_]+)\]`)
-	matches := r.FindStringSubmatch(s[0])
-	typeIndex := r.SubexpIndex("type")
-	typ := matches[typeIndex]
-	s = s[1:]
-	fields := []file.TypedComponent{}
-	i := 0
-	for ; i < len(s); i++ {
-		if isSection(s[i]) {
+func (p *mdParser) sections(nodes []ast.Node) *file.File {
+	f := file.File{}
+	seen := map[string]int{}
+	for _, n := range nodes {
+		h, ok := n.(*ast.Heading)
+		if !ok || h.Level != 2 {
+			continue
+		}
+		line := p.line(h)
+		section := p.text(h)
+		key := section
+		if strings.HasPrefix(key, "type") {
+			key = "type"
+		}
+		if first, ok := seen[key]; ok {
+			p.diags.Errorf(line, "duplicate section %s, first declared at line %d", key, first)
+		}
+		seen[key] = line
+
+		body := siblings(h, 2)
+		switch key {
+		case "vars":
+			f.Vars = p.components(body, checkVarType)
+		case "after":
+			f.After = p.components(body, checkVarType)
+		case "assert":
+			f.Asserts = p.components(body, checkAssertType)
+		case "requires":
+			for _, v := range p.list(body) {
+				f.Requires = append(f.Requires, strings.TrimSpace(v.Val))
+			}
+		case "type":
+			f.Typ = p.apiType(section, line, body)
+		default:
+			p.diags.Warnf(line, "unknown section %s is ignored", section)
+		}
+	}
+	return &f
+}
+
+// siblings returns the blocks following the heading up to the next heading
+// of the same or a higher level.
+func siblings(h *ast.Heading, level int) []ast.Node {
+	nodes := []ast.Node{}
+	for n := h.NextSibling(); n != nil; n = n.NextSibling() {
+		if next, ok := n.(*ast.Heading); ok && next.Level <= level {
 			break
 		}
-		if isSubSection(s[i]) {
-			skip, v := parseTypedComponent(s[i:])
-			i += skip
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+func (p *mdParser) line(n ast.Node) int {
+	return bytes.Count(p.src[:n.Pos()], []byte("\n")) + 1
+}
+
+func (p *mdParser) text(n ast.Node) string {
+	sb := strings.Builder{}
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		seg := lines.At(i)
+		sb.Write(seg.Value(p.src))
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+// checkFences reports the fenced blocks which run to the end of the file or
+// of their container. The closing line is compared without the blockquote
+// markers and the indentation of the container.
+func (p *mdParser) checkFences(doc ast.Node) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		fence, ok := n.(*ast.FencedCodeBlock)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		opening := p.lineAt(fence.Pos())
+		marker := strings.TrimLeft(opening, " ")
+		size := len(marker) - len(strings.TrimLeft(marker, marker[:1]))
+
+		end := fence.Pos() + len(opening) + 1
+		if lines := fence.Lines(); lines.Len() > 0 {
+			end = lines.At(lines.Len() - 1).Stop
+		}
+		cutset := " \t"
+		if p.inBlockquote(fence) {
+			cutset += ">"
+		}
+		closing := ""
+		if end < len(p.src) {
+			closing = strings.TrimSpace(strings.TrimLeft(p.lineAt(end), cutset))
+		}
+		if len(closing) < size || strings.Trim(closing, marker[:1]) != "" {
+			p.diags.Errorf(p.line(fence), "unclosed code fence")
+		}
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+func (p *mdParser) inBlockquote(n ast.Node) bool {
+	for parent := n.Parent(); parent != nil; parent = parent.Parent() {
+		if parent.Kind() == ast.KindBlockquote {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *mdParser) lineAt(pos int) string {
+	line := p.src[pos:]
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return string(line)
+}
+
+type typeCheck func(t file.TypedComponent, diags *file.Diagnostics)
 
-			fields = append(fields, v)
+func checkVarType(t file.TypedComponent, diags *file.Diagnostics) {
+	if !slices.Contains(file.GetSupportedTypes(), t.Typ) {
+		diags.Errorf(t.Line, "%s has unknown var type %s", t.Nam, t.Typ)
+	}
+	for _, c := range t.Convs {
+		if !converters.IsSupported(c) {
+			diags.Errorf(t.Line, "%s has unknown converter %s", t.Nam, c)
 		}
 	}
+}
 
-	return i, file.APIType{
+func checkAssertType(t file.TypedComponent, diags *file.Diagnostics) {
+	if !slices.Contains(assertions.GetSupportedTypes(), t.Typ) {
+		diags.Errorf(t.Line, "%s has unknown assert type %s", t.Nam, t.Typ)
+	}
+}
+
+var (
+	typeHeader      = regexp.MustCompile(`^type\[(?P<type>[a-zA-Z0-9_]+)\]$`)
+	componentHeader = regexp.MustCompile(`^(?P<name>[a-zA-Z0-9_]+)(?:\[(?P<type>[a-zA-Z0-9_]+)\])?(?::(?P<converter>\S+))?$`)
+)
+
+func (p *mdParser) apiType(section string, line int, body []ast.Node) file.APIType {
+	typ := ""
+	matches := typeHeader.FindStringSubmatch(section)
+	if matches == nil {
+		p.diags.Errorf(line, "malformed type section %q, expected ## type[name]", section)
+	} else {
+		typ = matches[typeHeader.SubexpIndex("type")]
+	}
+	return file.APIType{
 		Typ:    typ,
-		Fields: fields,
+		Fields: p.components(body, checkVarType),
+		Line:   line,
 	}
 }
 
-func parseVars(s []string) (int, []file.TypedComponent) {
-	s = s[1:]
-	vars := []file.TypedComponent{}
-	i := 0
-	for ; i < len(s); i++ {
-		if isSection(s[i]) {
-			break
+func (p *mdParser) components(body []ast.Node, check typeCheck) file.TypedComponents {
+	ts := file.TypedComponents{}
+	for _, n := range body {
+		h, ok := n.(*ast.Heading)
+		if !ok || h.Level != 3 {
+			continue
 		}
-		if isSubSection(s[i]) {
-			skip, v := parseTypedComponent(s[i:])
-			i += skip
-			vars = append(vars, v)
+		t, ok := p.component(h)
+		if !ok {
+			continue
 		}
+		check(t, &p.diags)
+		ts = append(ts, t)
 	}
-
-	return i, vars
+	return ts
 }
 
-func parseTypedComponent(s []string) (int, file.TypedComponent) {
-	r := regexp.MustCompile(`### (?P<name>[a-zA-Z0-9_]+)(?:\[(?P<type>[a-zA-Z0-9_]+)\])?(?::(?P<converter>.+$))?`)
-	matches := r.FindStringSubmatch(s[0])
-	nameIndex := r.SubexpIndex("name")
-	typeIndex := r.SubexpIndex("type")
-	converterIndex := r.SubexpIndex("converter")
-	varName := matches[nameIndex]
-	varType := matches[typeIndex]
-	varConvs := matches[converterIndex]
+func (p *mdParser) component(h *ast.Heading) (file.TypedComponent, bool) {
+	header := p.text(h)
+	matches := componentHeader.FindStringSubmatch(header)
+	if matches == nil {
+		p.diags.Errorf(p.line(h), "malformed header %q, expected ### name[type]:converter", header)
+		return file.TypedComponent{}, false
+	}
+	varName := matches[componentHeader.SubexpIndex("name")]
+	varType := matches[componentHeader.SubexpIndex("type")]
+	varConvs := matches[componentHeader.SubexpIndex("converter")]
 
+	body := siblings(h, 3)
 	vals := []file.Value{}
-	i := 0
 	switch varType {
 	case file.ListType:
-		skip, multipleVals := parseList(s[1:])
-		vals = multipleVals
-		i = skip
-	case file.TextType:
-		skip, singleVal := parseText(s[1:])
-		vals = append(vals, singleVal)
-		i = skip
+		vals = p.list(body)
+		if len(vals) == 0 {
+			p.diags.Errorf(p.line(h), "%s is a list without items", varName)
+		}
 	default:
-		skip, singleVal := parseText(s[1:])
-		vals = append(vals, singleVal)
-		i = skip
+		vals = append(vals, p.code(body))
 	}
 
-	convs := strings.Split(varConvs, ":")
-	if len(convs) == 1 && convs[0] == "" {
-		convs = []string{}
+	convs := []string{}
+	flags := []string{}
+	for _, v := range strings.Split(varConvs, ":") {
+		switch {
+		case v == "":
+		case file.IsFlag(v):
+			flags = append(flags, v)
+		default:
+			convs = append(convs, v)
+		}
 	}
 
 	if varType == "" {
 		varType = "text"
 	}
 
-	return i, file.TypedComponent{Nam: varName, Typ: varType, Vals: vals, Convs: convs}
+	return file.TypedComponent{Nam: varName, Typ: varType, Vals: vals, Convs: convs, Flags: flags, Line: p.line(h)}, true
 }
 
-func parseList(s []string) (int, []file.Value) {
+func (p *mdParser) list(body []ast.Node) []file.Value {
 	vals := []file.Value{}
-	i := 0
-	for ; i < len(s); i++ {
-		if isSection(s[i]) || isSubSection(s[i]) {
-			break
+	for _, n := range body {
+		l, ok := n.(*ast.List)
+		if !ok {
+			continue
 		}
-		if strings.HasPrefix(s[i], "- ") {
+		for item := l.FirstChild(); item != nil; item = item.NextSibling() {
+			val := ""
+			if item.FirstChild() != nil {
+				val = p.text(item.FirstChild())
+			}
 			vals = append(vals, file.Value{
-				Val: strings.TrimPrefix(s[i], "- "),
+				Val: val,
 				Typ: file.TextType,
 			})
 		}
+		break
 	}
-	return i, vals
+	return vals
 }
 
-func parseText(s []string) (int, file.Value) {
-	textType := file.TextType
-	found := false
-	stringBuilder := strings.Builder{}
-	i := 0
-	for ; i < len(s); i++ {
-		if isSection(s[i]) || isSubSection(s[i]) {
-			break
-		}
-		if strings.HasPrefix(s[i], "```") {
-			if !found {
-				trimed := strings.TrimSpace(strings.TrimPrefix(s[i], "```"))
-				if trimed != "" {
-					textType = trimed
+// code reads the first code block (fenced or indented).
+func (p *mdParser) code(body []ast.Node) file.Value {
+	for _, n := range body {
+		switch b := n.(type) {
+		case *ast.FencedCodeBlock:
+			textType := file.TextType
+			if b.Info != nil {
+				if info := strings.TrimSpace(string(b.Info.Segment.Value(p.src))); info != "" {
+					textType = info
 				}
-				found = true
-				continue
 			}
-			break
+			return file.Value{Val: p.text(b), Typ: textType}
+		case *ast.CodeBlock:
+			return file.Value{Val: p.text(b), Typ: file.TextType}
 		}
-		if found {
-			stringBuilder.WriteString(s[i])
-			stringBuilder.WriteString("\n")
+	}
+	return file.Value{Typ: file.TextType}
+}
+
+func fileListReplacement(ts file.TypedComponents, vars varsPkg.Vars) (file.TypedComponents, error) {
+	newTs := make(file.TypedComponents, 0, len(ts))
+	for _, t := range ts {
+		switch t.Typ {
+		case file.ScriptListType:
+			val, err := varsPkg.Render(t.Vals[0].Val, vars)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", t.Nam, err)
+			}
+			val, err = command.RunCommand(val)
+			if err != nil {
+				return nil, fmt.Errorf("failed to run command %s: %w", secrets.Redact(val), err)
+			}
+			values := strings.Split(val, "\n")
+
+			newVals := make([]file.Value, 0, len(values))
+			for _, v := range values {
+				newVals = append(newVals, file.Value{
+					Val: v,
+					Typ: file.TextType,
+				})
+			}
+
+			newT := file.TypedComponent{
+				Nam:   t.Nam,
+				Typ:   file.ListType,
+				Convs: t.Convs,
+				Flags: t.Flags,
+				Vals:  newVals,
+				Line:  t.Line,
+			}
+
+			newTs = append(newTs, newT)
+		default:
+			newTs = append(newTs, t)
+			continue
 		}
+
 	}
-	return i, file.Value{Val: strings.TrimSpace(stringBuilder.String()), Typ: textType}
+	return newTs, nil
 }
 
 func readFileList(filePath string) ([]string, error) {
@@ -0,0 +1,235 @@
+package parser
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/catmorte/go-mdapi/internal/file"
+)
+
+func fenceErrors(diags file.Diagnostics) []int {
+	lines := []int{}
+	for _, d := range diags {
+		if d.Msg == "unclosed code fence" {
+			lines = append(lines, d.Line)
+		}
+	}
+	return lines
+}
+
+func TestCheckFences(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []int
+	}{
+		{"closed", "```\na\n```\n", nil},
+		{"closed at the end of the file", "```\na\n```", nil},
+		{"empty", "```\n```\n", nil},
+		{"longer closing fence", "```\na\n`````\n", nil},
+		{"tildes", "~~~\na\n~~~\n", nil},
+		{"indented", "  ```\n  a\n  ```\n", nil},
+		{"blockquote", "> ```\n> a\n> ```\n", nil},
+		{"nested blockquote", "> > ```\n> > a\n> > ```\n", nil},
+		{"list item", "- item\n\n  ```\n  a\n  ```\n", nil},
+		{"list item in blockquote", "> - item\n>\n>   ```\n>   a\n>   ```\n", nil},
+		{"unclosed", "```\na\n", []int{1}},
+		{"shorter closing fence", "````\na\n```\n", []int{1}},
+		{"other marker", "```\na\n~~~\n", []int{1}},
+		{"unclosed in blockquote", "> ```\n> a\n", []int{1}},
+		{"unclosed after a closed one", "```\na\n```\n\n```\nb\n", []int{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, diags := parse([]byte(tt.src))
+			if got := fenceErrors(diags); !slices.Equal(got, tt.want) {
+				t.Errorf("unclosed fences at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+const multiSrc = `## vars
+
+### host
+
+` + "```" + `
+localhost
+` + "```" + `
+
+# create user
+
+## type[http]
+
+### url
+
+` + "```" + `
+{{host}}/users
+` + "```" + `
+
+## after
+
+### id[jsonpath]
+
+` + "```" + `
+$.id
+` + "```" + `
+
+# get user
+
+## type[http]
+
+### url
+
+` + "```" + `
+{{host}}/users/{{id}}
+` + "```" + `
+`
+
+func TestParseRequests(t *testing.T) {
+	fs, diags := parse([]byte(multiSrc))
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+	if len(fs) != 2 || fs[0].Nam != "create_user" || fs[1].Nam != "get_user" {
+		t.Fatalf("requests = %v", fs)
+	}
+	// the vars above the first heading are shared
+	for _, f := range fs {
+		if _, ok := f.GetVarByName("host"); !ok {
+			t.Errorf("%s misses the shared host var", f.Nam)
+		}
+	}
+	if len(fs[1].After) != 0 {
+		t.Errorf("get_user has the after of create_user")
+	}
+}
+
+func TestParseDiagnostics(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"empty list", "## vars\n\n### env[list]\n\n## type[http]\n", "line 3: env is a list without items"},
+		{"malformed header", "## vars\n\n### a b\n\n## type[http]\n", `line 3: malformed header "a b", expected ### name[type]:converter`},
+		{"malformed type", "## type[http\n", `line 1: malformed type section "type[http", expected ## type[name]`},
+		{"unknown var type", "## vars\n\n### a[nope]\n\n## type[http]\n", "line 3: a has unknown var type nope"},
+		{"unknown converter", "## vars\n\n### a:nope\n\n## type[http]\n", "line 3: a has unknown converter nope"},
+		{"duplicate section", "## vars\n\n## vars\n\n## type[http]\n", "line 3: duplicate section vars, first declared at line 1"},
+		{"duplicate request", "# a\n\n## type[http]\n\n# a\n\n## type[http]\n", "line 5: duplicate request a, first declared at line 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, diags := parse([]byte(tt.src))
+			err := diags.Err()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseComponents(t *testing.T) {
+	src := "## vars\n\n" +
+		"### token:secret:base64\n\n```json\n{\"a\": 1}\n```\n\n" +
+		"### env[list]\n\n- dev\n- prod\n\n" +
+		"### indented\n\n    indented code\n\n" +
+		"### empty\n\n" +
+		"## requires\n\n- login.md\n- #create\n\n" +
+		"## type[http]\n\n### url\n\n```\nx\n```\n\n" +
+		"## notes\n"
+	fs, diags := parse([]byte(src))
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+	f := fs[0]
+	if got := names(f.Vars); !slices.Equal(got, []string{"token", "env", "indented", "empty"}) {
+		t.Fatalf("vars = %v", got)
+	}
+
+	token := f.Vars[0]
+	if token.Typ != file.TextType || !slices.Equal(token.Flags, []string{file.SecretFlag}) || !slices.Equal(token.Convs, []string{"base64"}) || token.Line != 3 {
+		t.Errorf("token = %+v", token)
+	}
+	if token.Vals[0].Val != `{"a": 1}` || token.Vals[0].Typ != "json" {
+		t.Errorf("token value = %+v", token.Vals[0])
+	}
+	if env := f.Vars[1]; env.Typ != file.ListType || len(env.Vals) != 2 || env.Vals[0].Val != "dev" || env.Vals[1].Val != "prod" {
+		t.Errorf("env = %+v", env)
+	}
+	if v := f.Vars[2].Vals[0].Val; v != "indented code" {
+		t.Errorf("indented = %q", v)
+	}
+	if v := f.Vars[3].Vals[0].Val; v != "" {
+		t.Errorf("empty = %q", v)
+	}
+	if !slices.Equal(f.Requires, []string{"login.md", "#create"}) {
+		t.Errorf("requires = %v", f.Requires)
+	}
+	if f.Typ.Typ != "http" || f.Typ.Line != 25 || len(f.Typ.Fields) != 1 {
+		t.Errorf("type = %+v", f.Typ)
+	}
+	if len(diags) != 1 || diags[0].Severity != file.SeverityWarning || diags[0].Msg != "unknown section notes is ignored" {
+		t.Errorf("diagnostics = %v", diags)
+	}
+}
+
+func TestParseSingleRequest(t *testing.T) {
+	// a single heading with a type isn't a named request
+	fs, diags := parse([]byte("# users\n\n## type[http]\n\n### url\n\n```\nx\n```\n"))
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+	if len(fs) != 1 || fs[0].Nam != "" {
+		t.Errorf("requests = %v", fs)
+	}
+}
+
+func TestParseRequestVarsOverShared(t *testing.T) {
+	src := "## vars\n\n### page\n\n```\n1\n```\n\n" +
+		"# first\n\n## vars\n\n### page\n\n```\n2\n```\n\n## type[http]\n\n### url\n\n```\nx\n```\n\n" +
+		"# second\n\n## type[http]\n\n### url\n\n```\nx\n```\n"
+	fs, diags := parse([]byte(src))
+	if diags.HasErrors() {
+		t.Fatal(diags.Err())
+	}
+	for _, tt := range []struct {
+		f    *file.File
+		want string
+	}{{fs[0], "2"}, {fs[1], "1"}} {
+		page, ok := tt.f.GetVarByName("page")
+		if !ok || len(tt.f.Vars) != 1 || page.Vals[0].Val != tt.want {
+			t.Errorf("%s: vars = %+v, want page %s", tt.f.Nam, tt.f.Vars, tt.want)
+		}
+	}
+}
+
+func TestRequirePath(t *testing.T) {
+	tests := []struct {
+		req      string
+		wantPath string
+		wantName string
+	}{
+		{"login.md", "dir/login.md", ""},
+		{"../auth/login.md", "auth/login.md", ""},
+		{"login.md#token", "dir/login.md", "token"},
+		{"#create", "dir/api.md", "create"},
+		{"/abs/login.md", "/abs/login.md", ""},
+	}
+	for _, tt := range tests {
+		path, name := RequirePath("dir/api.md", tt.req)
+		if path != tt.wantPath || name != tt.wantName {
+			t.Errorf("RequirePath(%q) = %q, %q, want %q, %q", tt.req, path, name, tt.wantPath, tt.wantName)
+		}
+	}
+}
+
+func names(ts file.TypedComponents) []string {
+	res := []string{}
+	for _, t := range ts {
+		res = append(res, t.Nam)
+	}
+	return res
+}
@@ -0,0 +1,77 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/file"
+)
+
+// RequirePath resolves a requires entry (file.md, file.md#name or #name for
+// a request of the same file) against the requiring file.
+func RequirePath(mdPath, req string) (string, string) {
+	reqPath, reqName, _ := strings.Cut(req, "#")
+	switch {
+	case reqPath == "":
+		reqPath = mdPath
+	case !filepath.IsAbs(reqPath):
+		reqPath = filepath.Join(filepath.Dir(mdPath), reqPath)
+	}
+	return reqPath, reqName
+}
+
+// Provided returns the after names of the requires of the request and of the
+// requests declared before it in the file. The required files are parsed,
+// not run.
+func Provided(mdPath string, f *file.File) ([]string, error) {
+	names := []string{}
+	earlier, err := Earlier(mdPath, f.Nam)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range earlier {
+		names = append(names, afterNames(other)...)
+	}
+	for _, req := range f.Requires {
+		reqPath, reqName := RequirePath(mdPath, req)
+		fs, _, err := Parse(reqPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read required %s: %w", req, err)
+		}
+		rf, err := selectRequest(reqPath, reqName, fs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read required %s: %w", req, err)
+		}
+		names = append(names, afterNames(rf)...)
+	}
+	return names, nil
+}
+
+// Earlier returns the requests declared before the named one in the file,
+// none for a file with a single request.
+func Earlier(mdPath, name string) ([]*file.File, error) {
+	if name == "" {
+		return nil, nil
+	}
+	fs, _, err := Parse(mdPath)
+	if err != nil {
+		return nil, err
+	}
+	earlier := []*file.File{}
+	for _, f := range fs {
+		if f.Nam == name {
+			break
+		}
+		earlier = append(earlier, f)
+	}
+	return earlier, nil
+}
+
+func afterNames(f *file.File) []string {
+	names := []string{}
+	for _, t := range f.After {
+		names = append(names, t.Nam)
+	}
+	return names
+}
@@ -0,0 +1,76 @@
+package prompt
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var ErrNoInput = errors.New("no input")
+
+type Prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func New(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{in: bufio.NewReader(in), out: out}
+}
+
+// Select shows the numbered options and returns the chosen one, an empty
+// answer chooses the first one.
+func (p *Prompter) Select(label string, options []string) (string, error) {
+	fmt.Fprintf(p.out, "%s:\n", label)
+	for i, o := range options {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, o)
+	}
+	for {
+		fmt.Fprintf(p.out, "select [1-%d] (1): ", len(options))
+		answer, err := p.readLine()
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			return options[0], nil
+		}
+		n, err := strconv.Atoi(answer)
+		if err == nil && n >= 1 && n <= len(options) {
+			return options[n-1], nil
+		}
+		fmt.Fprintf(p.out, "unknown option %s\n", answer)
+	}
+}
+
+// Input returns the typed value, empty if nothing was typed. shown is the
+// default displayed in brackets, required repeats the question until
+// something is typed.
+func (p *Prompter) Input(label, shown string, required bool) (string, error) {
+	for {
+		if shown != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", label, shown)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", label)
+		}
+		answer, err := p.readLine()
+		if err != nil {
+			return "", err
+		}
+		if answer != "" || !required {
+			return answer, nil
+		}
+	}
+}
+
+func (p *Prompter) readLine() (string, error) {
+	line, err := p.in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return "", ErrNoInput
+		}
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
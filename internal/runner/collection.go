@@ -0,0 +1,79 @@
+package runner
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/catmorte/go-mdapi/internal/parser"
+)
+
+func Collect(dir string) ([]string, error) {
+	paths := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".md" {
+			return nil
+		}
+		ok, err := parser.IsAPIFile(path)
+		if err != nil {
+			return err
+		}
+		if ok {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// RunAll runs the files in parallel, the requests of a file run in order.
+func (r *Runner) RunAll(paths []string, vars map[string]string, parallel int) []Result {
+	if parallel < 1 {
+		parallel = 1
+	}
+	fileResults := make([][]Result, len(paths))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fileResults[i] = r.RunFile(paths[i], copyVars(vars))
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	results := []Result{}
+	for _, res := range fileResults {
+		results = append(results, res...)
+	}
+	return results
+}
+
+func copyVars(vars map[string]string) map[string]string {
+	res := make(map[string]string, len(vars))
+	for k, v := range vars {
+		res[k] = v
+	}
+	return res
+}
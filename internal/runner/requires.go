@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/catmorte/go-mdapi/internal/assertions"
+	"github.com/catmorte/go-mdapi/internal/file"
+	"github.com/catmorte/go-mdapi/internal/parser"
+	"github.com/catmorte/go-mdapi/internal/secrets"
+	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
+)
+
+var ErrCycle = errors.New("requires cycle")
+
+const cacheKeyFile = ".cache"
+
+func (r *Runner) require(mdPath, name string, requires []string, cliVars map[string]string, allFields varsPkg.Vars, stack []string) error {
+	absPath, err := filepath.Abs(mdPath)
+	if err != nil {
+		return err
+	}
+	stack = append(stack, requestPath(absPath, name))
+	for _, req := range requires {
+		reqPath, reqName := parser.RequirePath(mdPath, req)
+		absReqPath, err := filepath.Abs(reqPath)
+		if err != nil {
+			return err
+		}
+		absReqPath = requestPath(absReqPath, reqName)
+		for i, v := range stack {
+			if v == absReqPath {
+				return fmt.Errorf("%w: %s -> %s", ErrCycle, strings.Join(stack[i:], " -> "), absReqPath)
+			}
+		}
+		after, err := r.requireOne(reqPath, reqName, absReqPath, cliVars, stack)
+		if err != nil {
+			return fmt.Errorf("failed to run required %s: %w", req, err)
+		}
+		for k, v := range after {
+			if _, ok := cliVars[k]; ok {
+				continue
+			}
+			allFields[k] = v
+		}
+	}
+	return nil
+}
+
+func (r *Runner) requireOne(reqPath, name, absReqPath string, cliVars map[string]string, stack []string) (map[string]string, error) {
+	defer r.lock(absReqPath)()
+
+	fileData, err := parser.ParseRequest(reqPath, name, PrepareVars(reqPath, copyVars(cliVars)))
+	if err != nil {
+		return nil, err
+	}
+	resdir := PrepareVars(reqPath, map[string]string{}).GetResultDir()
+	if fileData.Nam != "" {
+		resdir = RequestResultDir(reqPath, fileData.Nam)
+	}
+	key, err := r.cacheKey(reqPath, cliVars)
+	if err != nil {
+		return nil, err
+	}
+	if r.isFresh(resdir, key) {
+		after, ok, err := readAfter(resdir, fileData.After)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return after, nil
+		}
+	}
+	res := Result{Path: requestPath(reqPath, name)}
+	err = r.run(reqPath, name, copyVars(cliVars), stack, &res, nil)
+	if err != nil {
+		return nil, err
+	}
+	if res.Asserts.Failed() {
+		return nil, fmt.Errorf("assertions failed:\n%s", res.Asserts.Report())
+	}
+	after := map[string]string{}
+	for _, v := range fileData.After {
+		after[v.Nam] = res.Vars[v.Nam]
+	}
+	return after, nil
+}
+
+// lock serializes the runs of a request which share its result dir, it
+// returns the unlock.
+func (r *Runner) lock(absPath string) func() {
+	l, _ := r.locks.LoadOrStore(absPath, &sync.Mutex{})
+	l.(*sync.Mutex).Lock()
+	return l.(*sync.Mutex).Unlock
+}
+
+// readAfter returns false when a cached value was redacted and so the api
+// has to be rerun to get it.
+func readAfter(resdir string, ts file.TypedComponents) (map[string]string, bool, error) {
+	after := map[string]string{}
+	for _, v := range ts {
+		raw, err := os.ReadFile(filepath.Join(resdir, v.Nam))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read after value %s: %w", v.Nam, err)
+		}
+		if strings.Contains(string(raw), secrets.Mask) {
+			return nil, false, nil
+		}
+		after[v.Nam] = string(raw)
+	}
+	return after, true, nil
+}
+
+// cacheKey hashes the file, the env and the vars a run of the file starts
+// with, a cached result is only reused for the same ones. Session values
+// are left out as the file may write them itself.
+func (r *Runner) cacheKey(mdPath string, vars map[string]string) (string, error) {
+	src, err := os.ReadFile(mdPath)
+	if err != nil {
+		return "", err
+	}
+	allFields, sources, err := r.Vars(mdPath, copyVars(vars))
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(src)
+	fmt.Fprintf(h, "\nenv=%s\n", r.Env)
+	for _, k := range slices.Sorted(maps.Keys(allFields)) {
+		if sources.Get(k) == SourceSession {
+			continue
+		}
+		fmt.Fprintf(h, "%q=%q\n", k, allFields[k])
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (r *Runner) isFresh(resdir, key string) bool {
+	if r.CacheTTL <= 0 {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(resdir, ".vars"))
+	if err != nil {
+		return false
+	}
+	if time.Since(info.ModTime()) > r.CacheTTL {
+		return false
+	}
+	cached, err := os.ReadFile(filepath.Join(resdir, cacheKeyFile))
+	if err != nil || string(cached) != key {
+		return false
+	}
+	report, err := os.ReadFile(filepath.Join(resdir, assertions.ReportFile))
+	if err == nil && strings.Contains(string(report), "FAIL ") {
+		return false
+	}
+	return true
+}
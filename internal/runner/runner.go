@@ -0,0 +1,391 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/catmorte/go-mdapi/internal/assertions"
+	"github.com/catmorte/go-mdapi/internal/file"
+	"github.com/catmorte/go-mdapi/internal/parser"
+	"github.com/catmorte/go-mdapi/internal/prompt"
+	"github.com/catmorte/go-mdapi/internal/secrets"
+	"github.com/catmorte/go-mdapi/internal/session"
+	"github.com/catmorte/go-mdapi/internal/types"
+	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
+)
+
+const ResultFolder = ".result"
+
+type (
+	Runner struct {
+		CfgPath  string
+		CacheTTL time.Duration
+		Env      string
+		// Name selects the request of a file declaring several of them.
+		Name string
+		// Prompter asks for the vars which aren't set when it's not nil.
+		Prompter *prompt.Prompter
+
+		locks sync.Map
+	}
+	Result struct {
+		Path      string
+		ResultDir string
+		Duration  time.Duration
+		Asserts   assertions.Results
+		Vars      varsPkg.Vars
+		After     []string
+		Err       error
+	}
+	// answer is a prompted value, asked false means the declared value was
+	// chosen.
+	answer struct {
+		val   string
+		asked bool
+	}
+)
+
+func (r Result) Failed() bool {
+	return r.Err != nil || r.Asserts.Failed()
+}
+
+func PrepareVars(mdPath string, vars map[string]string) varsPkg.Vars {
+	curdir := filepath.Dir(mdPath)
+	curfile := strings.TrimSuffix(filepath.Base(mdPath), filepath.Ext(mdPath))
+	resdir := filepath.Join(curdir, ResultFolder, curfile)
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	allFields := varsPkg.Vars(vars)
+	allFields.SetCurrentDir(curdir)
+	allFields.SetCurrentFile(curfile)
+	allFields.SetResultDir(resdir)
+	return allFields
+}
+
+// RequestResultDir is the result dir of a request of a file declaring
+// several of them.
+func RequestResultDir(mdPath, name string) string {
+	return PrepareVars(mdPath, map[string]string{}).GetResultDir() + "." + name
+}
+
+func New(cfgPath string) *Runner {
+	return &Runner{CfgPath: cfgPath}
+}
+
+func (r *Runner) Run(mdPath string, vars map[string]string) Result {
+	return r.runRequest(mdPath, r.Name, vars, nil)
+}
+
+// RunFile runs the selected request or, when none is selected, all the
+// requests of the file in order. The after values of a request are passed
+// to the next ones unless set from the cli and a var is prompted once for
+// all of them. It stops on the first failure.
+func (r *Runner) RunFile(mdPath string, vars map[string]string) []Result {
+	names, err := parser.RequestNames(mdPath)
+	if err != nil {
+		return []Result{{Path: mdPath, Err: err}}
+	}
+	if r.Name != "" || len(names) == 0 {
+		return []Result{r.Run(mdPath, vars)}
+	}
+	shared := copyVars(vars)
+	answers := map[string]answer{}
+	results := []Result{}
+	for _, name := range names {
+		res := r.runRequest(mdPath, name, shared, answers)
+		results = append(results, res)
+		if res.Failed() {
+			return results
+		}
+		for _, k := range res.After {
+			if _, ok := vars[k]; !ok {
+				shared[k] = res.Vars[k]
+			}
+		}
+	}
+	return results
+}
+
+func (r *Runner) runRequest(mdPath, name string, vars map[string]string, answers map[string]answer) Result {
+	started := time.Now()
+	res := Result{Path: requestPath(mdPath, name)}
+	res.Err = r.run(mdPath, name, copyVars(vars), nil, &res, answers)
+	res.Duration = time.Since(started)
+	return res
+}
+
+func requestPath(mdPath, name string) string {
+	if name == "" {
+		return mdPath
+	}
+	return mdPath + "#" + name
+}
+
+func (r *Runner) run(mdPath, name string, vars map[string]string, stack []string, res *Result, answers map[string]answer) error {
+	cliVars := copyVars(vars)
+	key, err := r.cacheKey(mdPath, cliVars)
+	if err != nil {
+		return err
+	}
+	fileData, allFields, err := r.parse(mdPath, name, vars)
+	if err != nil {
+		return err
+	}
+	err = r.require(mdPath, name, fileData.Requires, cliVars, allFields, stack)
+	if err != nil {
+		return err
+	}
+	err = r.earlierAfter(mdPath, fileData, allFields, true)
+	if err != nil {
+		return err
+	}
+	if stack == nil {
+		// a direct run takes the lock the file gets as a require, once its
+		// own requires are done so no lock is held while waiting for another
+		absPath, err := filepath.Abs(mdPath)
+		if err != nil {
+			return err
+		}
+		defer r.lock(requestPath(absPath, name))()
+	}
+	// the provided values are set now, the missing ones are undefined
+	fileData.Provided = nil
+	resdir := allFields.GetResultDir()
+	curdir := allFields.GetCurrentDir()
+	dt, err := r.compute(fileData, allFields, answers)
+	if err != nil {
+		return err
+	}
+	res.Vars = allFields
+	for _, v := range fileData.After {
+		res.After = append(res.After, v.Nam)
+	}
+	err = rotateResultDir(resdir, curdir, filepath.Base(resdir))
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(resdir, 0o755)
+	if err != nil {
+		return fmt.Errorf("failed to create result dir: %w", err)
+	}
+	res.ResultDir = resdir
+	err = dt.Run(allFields)
+	if err != nil {
+		return fmt.Errorf("failed to run: %w", err)
+	}
+	varsFile := filepath.Join(resdir, ".vars")
+	jsonVarsRaw, err := json.MarshalIndent(secrets.RedactMap(allFields), "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to convert fields to json: %w", err)
+	}
+	err = os.WriteFile(varsFile, jsonVarsRaw, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write vars: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(resdir, cacheKeyFile), []byte(key), 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write cache key: %w", err)
+	}
+	err = fileData.After.Compute(allFields, true)
+	if err != nil {
+		return fmt.Errorf("failed to compute after: %w", err)
+	}
+	sessionValues := session.Store{}
+	for _, v := range fileData.After {
+		afterField := filepath.Join(resdir, v.Nam)
+		err = os.WriteFile(afterField, []byte(secrets.Redact(allFields[v.Nam])), 0o644)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %w", v.Nam, err)
+		}
+		if v.HasFlag(file.SessionFlag) {
+			sessionValues[v.Nam] = session.Value{
+				Val:     allFields[v.Nam],
+				Secret:  v.HasFlag(file.SecretFlag),
+				Updated: time.Now(),
+			}
+		}
+	}
+	if len(sessionValues) > 0 {
+		err = session.Update(SessionPath(mdPath, r.Env), sessionValues)
+		if err != nil {
+			return fmt.Errorf("failed to update session: %w", err)
+		}
+	}
+	if len(fileData.Asserts) == 0 {
+		return nil
+	}
+	res.Asserts = assertions.Evaluate(fileData.Asserts, allFields)
+	err = os.WriteFile(filepath.Join(resdir, assertions.ReportFile), []byte(secrets.Redact(res.Asserts.Report())), 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to write assert report: %w", err)
+	}
+	return nil
+}
+
+// Prepare parses the file and computes its vars and type fields without
+// running it or its requires.
+func (r *Runner) Prepare(mdPath string, vars map[string]string) (*file.File, varsPkg.Vars, types.DefinedType, error) {
+	return r.PrepareRequest(mdPath, r.Name, vars)
+}
+
+func (r *Runner) PrepareRequest(mdPath, name string, vars map[string]string) (*file.File, varsPkg.Vars, types.DefinedType, error) {
+	fileData, allFields, err := r.parse(mdPath, name, vars)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	err = r.earlierAfter(mdPath, fileData, allFields, false)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	dt, err := r.compute(fileData, allFields, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return fileData, allFields, dt, nil
+}
+
+func (r *Runner) parse(mdPath, name string, vars map[string]string) (*file.File, varsPkg.Vars, error) {
+	allFields, _, err := r.Vars(mdPath, vars)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileData, err := parser.ParseRequest(mdPath, name, allFields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prepare: %w", err)
+	}
+	if fileData.Nam != "" {
+		allFields.SetResultDir(RequestResultDir(mdPath, fileData.Nam))
+	}
+	return fileData, allFields, nil
+}
+
+// earlierAfter sets the after values of the earlier requests of the file
+// which the request uses and which aren't set, from their last results.
+// With required set a value which can't be read is an error, otherwise it's
+// left unset.
+func (r *Runner) earlierAfter(mdPath string, fileData *file.File, allFields varsPkg.Vars, required bool) error {
+	earlier, err := parser.Earlier(mdPath, fileData.Nam)
+	if err != nil {
+		return err
+	}
+	for _, e := range earlier {
+		for _, t := range e.After {
+			if _, ok := allFields[t.Nam]; ok || !fileData.Uses(t.Nam) {
+				continue
+			}
+			after, ok, err := readAfter(RequestResultDir(mdPath, e.Nam), file.TypedComponents{t})
+			switch {
+			case !required:
+			case err != nil:
+				return fmt.Errorf("%s is provided by %s, run it first", t.Nam, e.Nam)
+			case !ok:
+				return fmt.Errorf("%s is provided by %s and redacted in its result, run them together", t.Nam, e.Nam)
+			}
+			if ok {
+				allFields[t.Nam] = after[t.Nam]
+			}
+		}
+	}
+	return nil
+}
+
+// compute prompts the vars which aren't set, the answers are reused when
+// answers isn't nil.
+func (r *Runner) compute(fileData *file.File, allFields varsPkg.Vars, answers map[string]answer) (types.DefinedType, error) {
+	var ask file.Ask
+	if r.Prompter != nil {
+		ask = func(t file.TypedComponent, vrs varsPkg.Vars) (string, bool, error) {
+			if a, ok := answers[t.Nam]; ok {
+				return a.val, a.asked, nil
+			}
+			val, asked, err := r.ask(t, vrs)
+			if err == nil && answers != nil {
+				answers[t.Nam] = answer{val: val, asked: asked}
+			}
+			return val, asked, err
+		}
+	}
+	_, err := fileData.Compute(allFields, ask)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute: %w", err)
+	}
+	dts, err := types.GetDefinedTypes(r.CfgPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get defined types: %w", err)
+	}
+	dt, err := dts.FindByName(fileData.Typ.Typ)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get defined type: %w", err)
+	}
+	err = fileData.Typ.Fields.Compute(allFields, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse type fields: %w", err)
+	}
+	names := make([]string, 0, len(fileData.Typ.Fields))
+	for _, f := range fileData.Typ.Fields {
+		names = append(names, f.Nam)
+	}
+	allFields.SetTypeFields(names)
+	return dt, nil
+}
+
+func (r *Runner) ask(t file.TypedComponent, vrs varsPkg.Vars) (string, bool, error) {
+	label := t.Nam
+	if t.HasFlag(file.RequiredFlag) {
+		label += " (required)"
+	}
+	switch t.Typ {
+	case file.ListType:
+		options := make([]string, 0, len(t.Vals))
+		for _, v := range t.Vals {
+			options = append(options, varsPkg.ReplacePatterns(v.Val, vrs))
+		}
+		if len(options) == 0 {
+			val, err := r.Prompter.Input(label, "", true)
+			return val, true, err
+		}
+		val, err := r.Prompter.Select(label, options)
+		return val, true, err
+	case file.TextType:
+		declared := ""
+		if len(t.Vals) > 0 {
+			declared = varsPkg.ReplacePatterns(t.Vals[0].Val, vrs)
+		}
+		shown := declared
+		if t.HasFlag(file.SecretFlag) && shown != "" {
+			shown = secrets.Mask
+		}
+		val, err := r.Prompter.Input(label, shown, declared == "" && t.HasFlag(file.RequiredFlag))
+		return val, val != "", err
+	case file.ScriptType:
+		val, err := r.Prompter.Input(label+" (empty runs the script)", "", false)
+		return val, val != "", err
+	}
+	return "", false, nil
+}
+
+func rotateResultDir(resdir, curdir, curfile string) error {
+	_, err := os.Stat(resdir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	counter := 1
+	for {
+		newPath := filepath.Join(curdir, ResultFolder, fmt.Sprintf("%s_%d", curfile, counter))
+		_, err = os.Stat(newPath)
+		if os.IsNotExist(err) {
+			err = os.Rename(resdir, newPath)
+			if err != nil {
+				return fmt.Errorf("failed to rename: %w", err)
+			}
+			return nil
+		}
+		counter++
+	}
+}
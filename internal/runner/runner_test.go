@@ -0,0 +1,273 @@
+package runner
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/catmorte/go-mdapi/internal/prompt"
+)
+
+// fence wraps the value in a code block.
+func fence(v string) string {
+	return "```\n" + v + "\n```\n"
+}
+
+func writeAPI(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestRunner(t *testing.T) *Runner {
+	return New(filepath.Join(t.TempDir(), "cfg"))
+}
+
+// echoServer answers with the query string of the request.
+func echoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func readBody(t *testing.T, res Result) string {
+	t.Helper()
+	body, err := os.ReadFile(filepath.Join(res.ResultDir, "body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestRunIgnoresVarsNamedAsTypeFields(t *testing.T) {
+	srv := echoServer(t)
+	tests := []struct {
+		name string
+		vars string
+		cli  map[string]string
+	}{
+		// a key without cert used to fail the tls setup
+		{"key var", "### key\n\n" + fence("abc"), nil},
+		{"timeout var", "### key\n\n" + fence("abc") + "\n### timeout\n\n" + fence("not a duration"), nil},
+		{"cli vars", "### key\n\n" + fence("abc"), map[string]string{"insecure": "maybe", "proxy": "::"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := writeAPI(t, t.TempDir(), "api.md", "## vars\n\n"+tt.vars+"\n"+
+				"## type[http]\n\n"+
+				"### url\n\n"+fence(srv.URL+"?key={{key}}"))
+
+			res := newTestRunner(t).Run(md, tt.cli)
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+			if got := readBody(t, res); got != "key=abc" {
+				t.Errorf("body = %q", got)
+			}
+		})
+	}
+}
+
+func TestRunReadsDeclaredTypeFields(t *testing.T) {
+	srv := echoServer(t)
+	md := writeAPI(t, t.TempDir(), "api.md", "## type[http]\n\n"+
+		"### url\n\n"+fence(srv.URL)+"\n"+
+		"### timeout\n\n"+fence("not a duration"))
+
+	res := newTestRunner(t).Run(md, nil)
+	if res.Err == nil || !strings.Contains(res.Err.Error(), "invalid timeout field") {
+		t.Errorf("err = %v, expected the declared timeout to be used", res.Err)
+	}
+}
+
+func TestRequireCachedWithSessionAfter(t *testing.T) {
+	logins := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			logins++
+			fmt.Fprintf(w, `{"token": "t%d"}`, logins)
+			return
+		}
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	// the login writes the token it's run with to the session
+	writeAPI(t, dir, "login.md", "## type[http]\n\n"+
+		"### url\n\n"+fence(srv.URL+"/login")+"\n"+
+		"## after\n\n"+
+		"### token[jsonpath]:session\n\n"+fence("$.token"))
+	use := writeAPI(t, dir, "use.md", "## requires\n\n- login.md\n\n"+
+		"## type[http]\n\n"+
+		"### url\n\n"+fence(srv.URL+"/use?token={{token}}"))
+
+	r := newTestRunner(t)
+	r.CacheTTL = time.Hour
+	for i := 0; i < 2; i++ {
+		res := r.Run(use, nil)
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if got := readBody(t, res); got != "token=t1" {
+			t.Errorf("run %d: body = %q", i, got)
+		}
+	}
+	if logins != 1 {
+		t.Errorf("logins = %d, expected the cached login to be reused", logins)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ResultFolder, "login_1")); err == nil {
+		t.Error("the cached login was run again")
+	}
+
+	// other cli vars are another cache entry
+	res := r.Run(use, map[string]string{"other": "1"})
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if logins != 2 {
+		t.Errorf("logins = %d, expected a new login for other vars", logins)
+	}
+}
+
+func TestRunEarlierRequestAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			w.Write([]byte(`{"id": "42"}`))
+			return
+		}
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	t.Cleanup(srv.Close)
+	md := writeAPI(t, t.TempDir(), "multi.md", "# create user\n\n"+
+		"## type[http]\n\n"+
+		"### method\n\n"+fence("POST")+"\n"+
+		"### url\n\n"+fence(srv.URL)+"\n"+
+		"## after\n\n"+
+		"### id[jsonpath]\n\n"+fence("$.id")+"\n"+
+		"# get user\n\n"+
+		"## type[http]\n\n"+
+		"### url\n\n"+fence(srv.URL+"?id={{id}}"))
+
+	r := newTestRunner(t)
+	r.Name = "get_user"
+	// compile doesn't need the value
+	if _, _, _, err := r.Prepare(md, nil); err != nil {
+		t.Fatal(err)
+	}
+	res := r.Run(md, nil)
+	if res.Err == nil || res.Err.Error() != "id is provided by create_user, run it first" {
+		t.Fatalf("err = %v", res.Err)
+	}
+
+	r.Name = "create_user"
+	if res := r.Run(md, nil); res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	// the value is read from the last result of the earlier request
+	r.Name = "get_user"
+	res = r.Run(md, nil)
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if got := readBody(t, res); got != "id=42" {
+		t.Errorf("body = %q", got)
+	}
+
+	// a value set from the cli wins
+	res = r.Run(md, map[string]string{"id": "7"})
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if got := readBody(t, res); got != "id=7" {
+		t.Errorf("body = %q", got)
+	}
+}
+
+func TestRunFilePromptsOnce(t *testing.T) {
+	srv := echoServer(t)
+	md := writeAPI(t, t.TempDir(), "multi.md", "## vars\n\n"+
+		"### user:required\n\n"+fence("")+"\n"+
+		"### page\n\n"+fence("1")+"\n"+
+		"# first\n\n"+
+		"## type[http]\n\n"+
+		"### url\n\n"+fence(srv.URL+"?user={{user}}&page={{page}}")+"\n"+
+		"# second\n\n"+
+		"## type[http]\n\n"+
+		"### url\n\n"+fence(srv.URL+"?user={{user}}&page={{page}}"))
+
+	// a typed user and the default page, the input ends after that
+	out := &strings.Builder{}
+	r := newTestRunner(t)
+	r.Prompter = prompt.New(strings.NewReader("bob\n\n"), out)
+	results := r.RunFile(md, nil)
+	if len(results) != 2 {
+		t.Fatalf("results = %v", results)
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if got := readBody(t, res); got != "user=bob&page=1" {
+			t.Errorf("%s: body = %q", res.Path, got)
+		}
+	}
+	if n := strings.Count(out.String(), "user (required)"); n != 1 {
+		t.Errorf("user asked %d times:\n%s", n, out)
+	}
+	if n := strings.Count(out.String(), "page ["); n != 1 {
+		t.Errorf("page asked %d times:\n%s", n, out)
+	}
+}
+
+func TestRunAllLocksRequiredFiles(t *testing.T) {
+	inFlight, maxInFlight := atomic.Int32{}, atomic.Int32{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/shared" {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				m := maxInFlight.Load()
+				if n <= m || maxInFlight.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		w.Write([]byte(`{"v": "1"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	// shared.md runs directly and as a require of the others at once
+	paths := []string{writeAPI(t, dir, "shared.md", "## type[http]\n\n"+
+		"### url\n\n"+fence(srv.URL+"/shared")+"\n"+
+		"## after\n\n"+
+		"### v[jsonpath]\n\n"+fence("$.v"))}
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		paths = append(paths, writeAPI(t, dir, name, "## requires\n\n- shared.md\n\n"+
+			"## type[http]\n\n"+
+			"### url\n\n"+fence(srv.URL+"/use?v={{v}}")))
+	}
+
+	for _, res := range newTestRunner(t).RunAll(paths, nil, len(paths)) {
+		if res.Err != nil {
+			t.Fatalf("%s: %v", res.Path, res.Err)
+		}
+	}
+	if n := maxInFlight.Load(); n != 1 {
+		t.Errorf("shared.md ran %d times at once", n)
+	}
+}
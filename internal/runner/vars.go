@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/env"
+	"github.com/catmorte/go-mdapi/internal/session"
+	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
+)
+
+const (
+	SourceCLI     = "cli"
+	SourceSession = "session"
+	SourceEnv     = "env"
+	SourceDefault = "default"
+)
+
+type Sources struct {
+	EnvPath     string
+	SessionPath string
+	Keys        map[string]string
+}
+
+func (s Sources) Get(key string) string {
+	source, ok := s.Keys[key]
+	if !ok {
+		return SourceDefault
+	}
+	return source
+}
+
+func (s Sources) Precedence() string {
+	parts := []string{SourceCLI, fmt.Sprintf("%s (%s)", SourceSession, s.SessionPath)}
+	if s.EnvPath != "" {
+		parts = append(parts, fmt.Sprintf("%s (%s)", SourceEnv, s.EnvPath))
+	}
+	parts = append(parts, "defaults")
+	return strings.Join(parts, " > ")
+}
+
+func SessionPath(mdPath, envName string) string {
+	return session.Path(filepath.Join(filepath.Dir(mdPath), ResultFolder), envName)
+}
+
+// Vars prepares the vars of the file, merging cli vars, the session store
+// and the env in that order of precedence.
+func (r *Runner) Vars(mdPath string, vars map[string]string) (varsPkg.Vars, Sources, error) {
+	sources := Sources{
+		SessionPath: SessionPath(mdPath, r.Env),
+		Keys:        map[string]string{},
+	}
+	for k := range vars {
+		sources.Keys[k] = SourceCLI
+	}
+	allFields := PrepareVars(mdPath, vars)
+	sessionKeys, err := session.Apply(sources.SessionPath, allFields)
+	if err != nil {
+		return nil, sources, fmt.Errorf("failed to load session: %w", err)
+	}
+	for _, k := range sessionKeys {
+		sources.Keys[k] = SourceSession
+	}
+	envPath, envKeys, err := env.Apply(r.Env, allFields.GetCurrentDir(), r.CfgPath, allFields)
+	if err != nil {
+		return nil, sources, err
+	}
+	sources.EnvPath = envPath
+	for _, k := range envKeys {
+		sources.Keys[k] = SourceEnv
+	}
+	return allFields, sources, nil
+}
@@ -0,0 +1,50 @@
+package secrets
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+const Mask = "******"
+
+var (
+	mu     sync.RWMutex
+	values = map[string]struct{}{}
+)
+
+func Add(value string) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	values[value] = struct{}{}
+}
+
+func Redact(s string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if len(values) == 0 {
+		return s
+	}
+	sorted := make([]string, 0, len(values))
+	for v := range values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	for _, v := range sorted {
+		s = strings.ReplaceAll(s, v, Mask)
+	}
+	return s
+}
+
+func RedactMap(m map[string]string) map[string]string {
+	res := make(map[string]string, len(m))
+	for k, v := range m {
+		res[k] = Redact(v)
+	}
+	return res
+}
@@ -0,0 +1,104 @@
+package session
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/catmorte/go-mdapi/internal/secrets"
+)
+
+type (
+	Value struct {
+		Val     string    `json:"value"`
+		Secret  bool      `json:"secret,omitempty"`
+		Updated time.Time `json:"updated"`
+	}
+	Store map[string]Value
+)
+
+var mu sync.Mutex
+
+func Path(resultFolder, env string) string {
+	if env == "" {
+		return filepath.Join(resultFolder, ".session.json")
+	}
+	return filepath.Join(resultFolder, ".session."+env+".json")
+}
+
+func Load(path string) (Store, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	return load(path)
+}
+
+func load(path string) (Store, error) {
+	s := Store{}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(raw, &s)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Apply loads the store into vars without overriding already set keys and
+// returns the keys taken from it.
+func Apply(path string, vars map[string]string) ([]string, error) {
+	s, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	applied := []string{}
+	for k, v := range s {
+		if v.Secret {
+			secrets.Add(v.Val)
+		}
+		if _, ok := vars[k]; ok {
+			continue
+		}
+		vars[k] = v.Val
+		applied = append(applied, k)
+	}
+	return applied, nil
+}
+
+func Update(path string, values Store) error {
+	mu.Lock()
+	defer mu.Unlock()
+	s, err := load(path)
+	if err != nil {
+		return err
+	}
+	for k, v := range values {
+		s[k] = v
+	}
+	raw, err := json.MarshalIndent(s, "", " ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, raw, 0o600)
+}
+
+func Clear(path string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	err := os.Remove(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -7,6 +7,7 @@ import (
 	"text/template"
 
 	"github.com/catmorte/go-mdapi/internal/command"
+	"github.com/catmorte/go-mdapi/internal/secrets"
 	"github.com/catmorte/go-mdapi/internal/vars"
 )
 
@@ -56,7 +57,7 @@ func (d externalType) Compile(vrs vars.Vars) error {
 	if err != nil {
 		return err
 	}
-	fmt.Println(tpl.String())
+	fmt.Println(secrets.Redact(tpl.String()))
 	return nil
 }
 
@@ -6,7 +6,12 @@ import (
 
 type FieldVar string
 
+// Get reads the field, vars of the same name which aren't declared in the
+// type section are ignored.
 func (f FieldVar) Get(vrs vars.Vars) (string, bool) {
+	if !vrs.IsTypeField(string(f)) {
+		return "", false
+	}
 	v, ok := vrs[string(f)]
 	return v, ok
 }
@@ -0,0 +1,173 @@
+package types
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/vars"
+)
+
+type internalGraphQL string
+
+const (
+	InternalGraphQLQueryField         FieldVar = "query"
+	InternalGraphQLVariablesField     FieldVar = "variables"
+	InternalGraphQLOperationNameField FieldVar = "operationName"
+)
+
+//go:embed internal_graphql_new_api.md
+var internalGraphQLTemplate internalGraphQL
+
+type (
+	graphQLRequest struct {
+		Query         string          `json:"query"`
+		Variables     json.RawMessage `json:"variables,omitempty"`
+		OperationName string          `json:"operationName,omitempty"`
+	}
+	graphQLResponse struct {
+		Data   json.RawMessage `json:"data"`
+		Errors json.RawMessage `json:"errors"`
+	}
+	graphQLError struct {
+		Message string `json:"message"`
+		Path    []any  `json:"path"`
+	}
+)
+
+func (d internalGraphQL) GetName() string {
+	return "graphql"
+}
+
+func (d internalGraphQL) NewAPI() string {
+	return string(internalGraphQLTemplate)
+}
+
+// Run posts the query and writes the data and errors of the response next
+// to the http result files, a response with errors is a failed run.
+func (d internalGraphQL) Run(vrs vars.Vars) error {
+	rq, err := d.request(vrs)
+	if err != nil {
+		return err
+	}
+	body, err := httpSend(vrs, rq)
+	if err != nil {
+		return err
+	}
+
+	resp := graphQLResponse{}
+	gqlErrors := []graphQLError{}
+	err = json.Unmarshal(body, &resp)
+	if err == nil && len(indentJSON(resp.Errors)) > 0 {
+		err = json.Unmarshal(resp.Errors, &gqlErrors)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid graphql response: %w", err)
+	}
+
+	resultDir := vrs.GetResultDir()
+	err = os.WriteFile(filepath.Join(resultDir, "data"), indentJSON(resp.Data), 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing data: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(resultDir, "errors"), indentJSON(resp.Errors), 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing errors: %w", err)
+	}
+
+	if len(gqlErrors) > 0 {
+		messages := []string{}
+		for _, e := range gqlErrors {
+			msg := e.Message
+			if len(e.Path) > 0 {
+				msg += fmt.Sprintf(" (path %v)", e.Path)
+			}
+			messages = append(messages, msg)
+		}
+		return fmt.Errorf("graphql errors: %s", strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+func indentJSON(raw json.RawMessage) []byte {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	buf := bytes.Buffer{}
+	if json.Indent(&buf, raw, "", "  ") != nil {
+		return raw
+	}
+	return buf.Bytes()
+}
+
+func (d internalGraphQL) request(vrs vars.Vars) (*http.Request, error) {
+	url, ok := InternalHTTPURLField.Get(vrs)
+	if !ok {
+		return nil, errors.New("missing url field")
+	}
+	query, ok := InternalGraphQLQueryField.Get(vrs)
+	if !ok {
+		return nil, errors.New("missing query field")
+	}
+
+	envelope := graphQLRequest{Query: query}
+	if variables, ok := InternalGraphQLVariablesField.Get(vrs); ok && strings.TrimSpace(variables) != "" {
+		if !json.Valid([]byte(variables)) {
+			return nil, errors.New("variables field is not valid json")
+		}
+		envelope.Variables = json.RawMessage(variables)
+	}
+	if op, ok := InternalGraphQLOperationNameField.Get(vrs); ok {
+		envelope.OperationName = strings.TrimSpace(op)
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, fmt.Errorf("error building graphql request: %w", err)
+	}
+
+	headers, err := internalHTTPTemplate.headers(vrs)
+	if err != nil {
+		return nil, err
+	}
+	if headers == nil {
+		headers = http.Header{}
+	}
+	if headers.Get("Content-Type") == "" {
+		headers.Set("Content-Type", "application/json")
+	}
+	if headers.Get("Accept") == "" {
+		headers.Set("Accept", "application/json")
+	}
+
+	rq, err := http.NewRequest(http.MethodPost, strings.TrimSpace(url), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	rq.Header = headers
+	return rq, nil
+}
+
+func (d internalGraphQL) Compile(vrs vars.Vars) error {
+	rq, err := d.request(vrs)
+	if err != nil {
+		return err
+	}
+	return httpDump(vrs, rq)
+}
+
+func (d internalGraphQL) GetVars() []string {
+	return append([]string{
+		string(InternalHTTPURLField),
+		string(InternalGraphQLQueryField),
+		string(InternalGraphQLVariablesField),
+		string(InternalGraphQLOperationNameField),
+		string(InternalHTTPHeadersField),
+	}, slices.Concat(httpClientVars(), httpCookiesVars(), httpAuthVars())...)
+}
@@ -0,0 +1,352 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/secrets"
+	"github.com/catmorte/go-mdapi/internal/vars"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+type internalGRPC string
+
+const (
+	InternalGRPCAddressField     FieldVar = "address"
+	InternalGRPCMethodField      FieldVar = "method"
+	InternalGRPCProtoField       FieldVar = "proto"
+	InternalGRPCImportPathsField FieldVar = "importPaths"
+	InternalGRPCMetadataField    FieldVar = "metadata"
+	InternalGRPCBodyField        FieldVar = "body"
+	InternalGRPCTLSField         FieldVar = "tls"
+)
+
+//go:embed internal_grpc_new_api.md
+var internalGRPCTemplate internalGRPC
+
+func (d internalGRPC) GetName() string {
+	return "grpc"
+}
+
+func (d internalGRPC) NewAPI() string {
+	return string(internalGRPCTemplate)
+}
+
+// Run calls the method with the body (a json array of messages for client
+// streaming) and writes the status (code and name), the headers and
+// trailers metadata and the json response (an array for server streaming).
+// A non OK status returned by the server is a result, failing to reach the
+// server is an error.
+func (d internalGRPC) Run(vrs vars.Vars) error {
+	address, ok := InternalGRPCAddressField.Get(vrs)
+	if !ok {
+		return errors.New("missing address field")
+	}
+	service, method, err := d.method(vrs)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := grpcContext(vrs)
+	if err != nil {
+		return err
+	}
+	defer cancel()
+
+	conn, err := grpcConn(strings.TrimSpace(address), vrs)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	md, types, err := d.descriptor(ctx, conn, service, method, vrs)
+	if err != nil {
+		return err
+	}
+	requests, err := grpcRequests(md, types, vrs)
+	if err != nil {
+		return err
+	}
+
+	var header, trailer metadata.MD
+	responses := []json.RawMessage{}
+	fullMethod := "/" + string(md.Parent().FullName()) + "/" + string(md.Name())
+	p := peer.Peer{}
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{
+		ServerStreams: md.IsStreamingServer(),
+		ClientStreams: md.IsStreamingClient(),
+	}, fullMethod, grpc.Peer(&p))
+	if err == nil {
+		err = grpcSend(stream, requests)
+	}
+	for err == nil {
+		resp := dynamicpb.NewMessage(md.Output())
+		err = stream.RecvMsg(resp)
+		if err != nil {
+			break
+		}
+		raw, mErr := protojson.MarshalOptions{Resolver: types}.Marshal(resp)
+		if mErr != nil {
+			return fmt.Errorf("error converting response to json: %w", mErr)
+		}
+		responses = append(responses, raw)
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	// without a peer the call didn't reach the server, a timeout is the
+	// client giving up, neither is a status of the server
+	if err != nil && (p.Addr == nil || ctx.Err() != nil) {
+		return fmt.Errorf("error calling %s: %w", fullMethod, err)
+	}
+	if stream != nil {
+		header, _ = stream.Header()
+		trailer = stream.Trailer()
+	}
+
+	return d.write(vrs.GetResultDir(), status.Convert(err), header, trailer, responses, md.IsStreamingServer())
+}
+
+func (d internalGRPC) write(resultDir string, st *status.Status, header, trailer metadata.MD, responses []json.RawMessage, streaming bool) error {
+	statusLine := fmt.Sprintf("%d %s", st.Code(), st.Code())
+	if st.Message() != "" {
+		statusLine += ": " + st.Message()
+	}
+	err := os.WriteFile(filepath.Join(resultDir, "status"), []byte(statusLine), 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing status: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(resultDir, "headers"), []byte(grpcMetadataString(header)), 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing headers: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(resultDir, "trailers"), []byte(grpcMetadataString(trailer)), 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing trailers: %w", err)
+	}
+
+	var body []byte
+	switch {
+	case streaming:
+		body, err = json.MarshalIndent(responses, "", "  ")
+	case len(responses) > 0:
+		buf := bytes.Buffer{}
+		err = json.Indent(&buf, responses[0], "", "  ")
+		body = buf.Bytes()
+	}
+	if err != nil {
+		return fmt.Errorf("error converting response to json: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(resultDir, "body"), body, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing body: %w", err)
+	}
+	return nil
+}
+
+func (d internalGRPC) method(vrs vars.Vars) (string, string, error) {
+	raw, ok := InternalGRPCMethodField.Get(vrs)
+	if !ok {
+		return "", "", errors.New("missing method field")
+	}
+	return grpcMethod(raw)
+}
+
+// descriptor resolves the method from the proto files or, if there are
+// none, from the server reflection.
+func (d internalGRPC) descriptor(ctx context.Context, conn *grpc.ClientConn, service, method string, vrs vars.Vars) (protoreflect.MethodDescriptor, *dynamicpb.Types, error) {
+	var files *protoregistry.Files
+	var err error
+	if protoFiles := grpcLines(InternalGRPCProtoField, vrs); len(protoFiles) > 0 {
+		files, err = grpcProtoFiles(ctx, protoFiles, grpcLines(InternalGRPCImportPathsField, vrs))
+	} else {
+		files, err = grpcReflectFiles(ctx, conn, service)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	desc, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown service %s: %w", service, err)
+	}
+	sd, ok := desc.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not a service", service)
+	}
+	md := sd.Methods().ByName(protoreflect.Name(method))
+	if md == nil {
+		return nil, nil, fmt.Errorf("unknown method %s of service %s", method, service)
+	}
+	return md, dynamicpb.NewTypes(files), nil
+}
+
+func (d internalGRPC) Compile(vrs vars.Vars) error {
+	address, ok := InternalGRPCAddressField.Get(vrs)
+	if !ok {
+		return errors.New("missing address field")
+	}
+	service, method, err := d.method(vrs)
+	if err != nil {
+		return err
+	}
+	md, err := grpcMetadata(vrs)
+	if err != nil {
+		return err
+	}
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("%s %s/%s\n", strings.TrimSpace(address), service, method))
+	sb.WriteString(grpcMetadataString(md))
+	if body, ok := InternalGRPCBodyField.Get(vrs); ok {
+		sb.WriteString("\n")
+		sb.WriteString(body)
+	}
+	fmt.Println(secrets.Redact(sb.String()))
+	return nil
+}
+
+func (d internalGRPC) GetVars() []string {
+	return []string{
+		string(InternalGRPCAddressField),
+		string(InternalGRPCMethodField),
+		string(InternalGRPCProtoField),
+		string(InternalGRPCImportPathsField),
+		string(InternalGRPCMetadataField),
+		string(InternalGRPCBodyField),
+		string(InternalGRPCTLSField),
+		string(InternalHTTPTimeoutField),
+		string(InternalHTTPCACertField),
+		string(InternalHTTPCertField),
+		string(InternalHTTPKeyField),
+		string(InternalHTTPInsecureField),
+	}
+}
+
+func grpcLines(f FieldVar, vrs vars.Vars) []string {
+	raw, _ := f.Get(vrs)
+	lines := []string{}
+	for _, line := range strings.Split(raw, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// grpcConn uses plain text unless the tls field is set, the tls settings
+// are the same as for the http type.
+func grpcConn(address string, vrs vars.Vars) (*grpc.ClientConn, error) {
+	useTLS, err := InternalGRPCTLSField.GetBool(vrs, false)
+	if err != nil {
+		return nil, err
+	}
+	creds := insecure.NewCredentials()
+	if useTLS {
+		tlsConfig, err := httpTLSConfig(vrs)
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	conn, err := grpc.NewClient(address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+func grpcContext(vrs vars.Vars) (context.Context, context.CancelFunc, error) {
+	timeout, err := InternalHTTPTimeoutField.GetDuration(vrs)
+	if err != nil {
+		return nil, nil, err
+	}
+	md, err := grpcMetadata(vrs)
+	if err != nil {
+		return nil, nil, err
+	}
+	ctx := metadata.NewOutgoingContext(context.Background(), md)
+	if timeout > 0 {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		return ctx, cancel, nil
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return ctx, cancel, nil
+}
+
+func grpcMetadata(vrs vars.Vars) (metadata.MD, error) {
+	md := metadata.MD{}
+	for _, line := range grpcLines(InternalGRPCMetadataField, vrs) {
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid metadata line: %s", line)
+		}
+		md.Append(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	return md, nil
+}
+
+func grpcMetadataString(md metadata.MD) string {
+	sb := strings.Builder{}
+	for key, values := range md {
+		for _, value := range values {
+			sb.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+		}
+	}
+	return sb.String()
+}
+
+func grpcRequests(md protoreflect.MethodDescriptor, types *dynamicpb.Types, vrs vars.Vars) ([]proto.Message, error) {
+	body, _ := InternalGRPCBodyField.Get(vrs)
+	body = strings.TrimSpace(body)
+	raws := []json.RawMessage{json.RawMessage(body)}
+	if md.IsStreamingClient() && strings.HasPrefix(body, "[") {
+		err := json.Unmarshal([]byte(body), &raws)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body: %w", err)
+		}
+	}
+	requests := []proto.Message{}
+	for _, raw := range raws {
+		msg := dynamicpb.NewMessage(md.Input())
+		if len(bytes.TrimSpace(raw)) > 0 {
+			err := protojson.UnmarshalOptions{Resolver: types}.Unmarshal(raw, msg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid body for %s: %w", md.Input().FullName(), err)
+			}
+		}
+		requests = append(requests, msg)
+	}
+	return requests, nil
+}
+
+func grpcSend(stream grpc.ClientStream, requests []proto.Message) error {
+	for _, rq := range requests {
+		err := stream.SendMsg(rq)
+		if err == io.EOF {
+			// the server ended the call, its status comes with RecvMsg
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return stream.CloseSend()
+}
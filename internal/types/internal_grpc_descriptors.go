@@ -0,0 +1,140 @@
+package types
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// grpcMethod splits pkg.Service/Method (or pkg.Service.Method) into the
+// service and the method names.
+func grpcMethod(raw string) (string, string, error) {
+	raw = strings.Trim(strings.TrimSpace(raw), "/")
+	i := strings.LastIndex(raw, "/")
+	if i < 0 {
+		i = strings.LastIndex(raw, ".")
+	}
+	if i <= 0 || i == len(raw)-1 {
+		return "", "", fmt.Errorf("invalid method %q, expected pkg.Service/Method", raw)
+	}
+	return raw[:i], raw[i+1:], nil
+}
+
+// grpcProtoFiles compiles the proto files found in the import paths (or
+// relative to the working directory).
+func grpcProtoFiles(ctx context.Context, protoFiles, importPaths []string) (*protoregistry.Files, error) {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{ImportPaths: importPaths}),
+	}
+	compiled, err := compiler.Compile(ctx, protoFiles...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile proto files: %w", err)
+	}
+	files := &protoregistry.Files{}
+	for _, f := range compiled {
+		err = registerFile(files, f)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func registerFile(files *protoregistry.Files, f protoreflect.FileDescriptor) error {
+	if _, err := files.FindFileByPath(f.Path()); err == nil {
+		return nil
+	}
+	imports := f.Imports()
+	for i := 0; i < imports.Len(); i++ {
+		err := registerFile(files, imports.Get(i).FileDescriptor)
+		if err != nil {
+			return err
+		}
+	}
+	return files.RegisterFile(f)
+}
+
+// grpcReflectFiles asks the server reflection for the file declaring the
+// service and its dependencies.
+func grpcReflectFiles(ctx context.Context, conn grpc.ClientConnInterface, service string) (*protoregistry.Files, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start reflection: %w", err)
+	}
+	defer stream.CloseSend()
+
+	protos := map[string]*descriptorpb.FileDescriptorProto{}
+	order := []string{}
+	pending := []*rpb.ServerReflectionRequest{{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	}}
+	for len(pending) > 0 {
+		err = stream.Send(pending[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to query reflection: %w", err)
+		}
+		pending = pending[1:]
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil, errors.New("reflection stream closed")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to query reflection: %w", err)
+		}
+		if e := resp.GetErrorResponse(); e != nil {
+			return nil, fmt.Errorf("reflection error for %s: %s", service, e.GetErrorMessage())
+		}
+		for _, raw := range resp.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			fd := &descriptorpb.FileDescriptorProto{}
+			err = proto.Unmarshal(raw, fd)
+			if err != nil {
+				return nil, fmt.Errorf("invalid file descriptor: %w", err)
+			}
+			if _, ok := protos[fd.GetName()]; ok {
+				continue
+			}
+			protos[fd.GetName()] = fd
+			order = append(order, fd.GetName())
+		}
+		for _, name := range order {
+			for _, dep := range protos[name].GetDependency() {
+				if _, ok := protos[dep]; ok || requested(pending, dep) {
+					continue
+				}
+				pending = append(pending, &rpb.ServerReflectionRequest{
+					MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+				})
+			}
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, name := range order {
+		set.File = append(set.File, protos[name])
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid descriptors from reflection: %w", err)
+	}
+	return files, nil
+}
+
+func requested(pending []*rpb.ServerReflectionRequest, name string) bool {
+	for _, rq := range pending {
+		if rq.GetFileByFilename() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,219 @@
+package types
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/catmorte/go-mdapi/internal/vars"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// the health service messages, compiled from source instead of the
+// generated descriptors of the server
+const healthProto = `syntax = "proto3";
+
+package grpc.health.v1;
+
+message HealthCheckRequest {
+  string service = 1;
+}
+
+message HealthCheckResponse {
+  enum ServingStatus {
+    UNKNOWN = 0;
+    SERVING = 1;
+    NOT_SERVING = 2;
+    SERVICE_UNKNOWN = 3;
+  }
+  ServingStatus status = 1;
+}
+
+service Health {
+  rpc Check(HealthCheckRequest) returns (HealthCheckResponse);
+}
+`
+
+// grpcServer serves the health service, with the reflection service when
+// reflect is set, and returns its address.
+func grpcServer(t *testing.T, reflect bool, opts ...grpc.ServerOption) string {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := grpc.NewServer(opts...)
+	hs := health.NewServer()
+	hs.SetServingStatus("users", healthpb.HealthCheckResponse_SERVING)
+	hs.SetServingStatus("orders", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(srv, hs)
+	if reflect {
+		reflection.Register(srv)
+	}
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func grpcVars(t *testing.T, fields map[string]string) vars.Vars {
+	t.Helper()
+	vrs := vars.Vars{
+		string(InternalHTTPTimeoutField): "5s",
+	}
+	for k, v := range fields {
+		vrs[k] = v
+	}
+	vrs.SetResultDir(t.TempDir())
+	return vrs
+}
+
+func readResult(t *testing.T, vrs vars.Vars, name string) string {
+	t.Helper()
+	raw, err := os.ReadFile(filepath.Join(vrs.GetResultDir(), name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(raw)
+}
+
+func checkHealthBody(t *testing.T, body, want string) {
+	t.Helper()
+	resp := map[string]string{}
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("body %q isn't json: %v", body, err)
+	}
+	if resp["status"] != want {
+		t.Errorf("status = %q, want %q", resp["status"], want)
+	}
+}
+
+func TestGRPCReflection(t *testing.T) {
+	address := grpcServer(t, true)
+	for _, tt := range []struct {
+		service string
+		want    string
+	}{
+		{"users", "SERVING"},
+		{"orders", "NOT_SERVING"},
+	} {
+		t.Run(tt.service, func(t *testing.T) {
+			vrs := grpcVars(t, map[string]string{
+				string(InternalGRPCAddressField): address,
+				string(InternalGRPCMethodField):  "grpc.health.v1.Health/Check",
+				string(InternalGRPCBodyField):    `{"service": "` + tt.service + `"}`,
+			})
+			if err := internalGRPCTemplate.Run(vrs); err != nil {
+				t.Fatal(err)
+			}
+			if got := readResult(t, vrs, "status"); got != "0 OK" {
+				t.Errorf("status = %q", got)
+			}
+			checkHealthBody(t, readResult(t, vrs, "body"), tt.want)
+		})
+	}
+}
+
+func TestGRPCProtoFiles(t *testing.T) {
+	// no reflection, the method has to come from the proto file
+	address := grpcServer(t, false)
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "health.proto"), []byte(healthProto), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vrs := grpcVars(t, map[string]string{
+		string(InternalGRPCAddressField):     address,
+		string(InternalGRPCMethodField):      "grpc.health.v1.Health.Check",
+		string(InternalGRPCProtoField):       "health.proto",
+		string(InternalGRPCImportPathsField): dir,
+		string(InternalGRPCBodyField):        `{"service": "users"}`,
+	})
+	if err := internalGRPCTemplate.Run(vrs); err != nil {
+		t.Fatal(err)
+	}
+	checkHealthBody(t, readResult(t, vrs, "body"), "SERVING")
+
+	// without reflection the proto files are required
+	vrs = grpcVars(t, map[string]string{
+		string(InternalGRPCAddressField): address,
+		string(InternalGRPCMethodField):  "grpc.health.v1.Health/Check",
+		string(InternalGRPCBodyField):    `{}`,
+	})
+	if err := internalGRPCTemplate.Run(vrs); err == nil {
+		t.Error("expected an error without proto files and reflection")
+	}
+}
+
+func TestGRPCErrorStatus(t *testing.T) {
+	address := grpcServer(t, true)
+	vrs := grpcVars(t, map[string]string{
+		string(InternalGRPCAddressField): address,
+		string(InternalGRPCMethodField):  "grpc.health.v1.Health/Check",
+		string(InternalGRPCBodyField):    `{"service": "missing"}`,
+	})
+	// a non OK status is a result, not an error
+	if err := internalGRPCTemplate.Run(vrs); err != nil {
+		t.Fatal(err)
+	}
+	if got := readResult(t, vrs, "status"); !strings.HasPrefix(got, "5 NotFound") {
+		t.Errorf("status = %q, want 5 NotFound", got)
+	}
+	if got := readResult(t, vrs, "body"); got != "" {
+		t.Errorf("body = %q, want empty", got)
+	}
+}
+
+func TestGRPCServerUnavailable(t *testing.T) {
+	// the server answers unavailable itself, that's a result
+	address := grpcServer(t, true, grpc.UnaryInterceptor(func(context.Context, any, *grpc.UnaryServerInfo, grpc.UnaryHandler) (any, error) {
+		return nil, status.Error(codes.Unavailable, "draining")
+	}))
+	vrs := grpcVars(t, map[string]string{
+		string(InternalGRPCAddressField): address,
+		string(InternalGRPCMethodField):  "grpc.health.v1.Health/Check",
+		string(InternalGRPCBodyField):    `{}`,
+	})
+	if err := internalGRPCTemplate.Run(vrs); err != nil {
+		t.Fatal(err)
+	}
+	if got := readResult(t, vrs, "status"); got != "14 Unavailable: draining" {
+		t.Errorf("status = %q", got)
+	}
+}
+
+func TestGRPCUnreachable(t *testing.T) {
+	// a closed port, the call never reaches a server
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := lis.Addr().String()
+	lis.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "health.proto"), []byte(healthProto), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	vrs := grpcVars(t, map[string]string{
+		string(InternalGRPCAddressField):     address,
+		string(InternalGRPCMethodField):      "grpc.health.v1.Health/Check",
+		string(InternalGRPCProtoField):       "health.proto",
+		string(InternalGRPCImportPathsField): dir,
+		string(InternalGRPCBodyField):        `{}`,
+	})
+	err = internalGRPCTemplate.Run(vrs)
+	if err == nil || !strings.Contains(err.Error(), "Unavailable") {
+		t.Errorf("err = %v, expected the unavailable server to fail the run", err)
+	}
+	if _, err := os.Stat(filepath.Join(vrs.GetResultDir(), "status")); err == nil {
+		t.Error("a status was written for a call which didn't happen")
+	}
+}
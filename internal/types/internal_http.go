@@ -8,10 +8,13 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
+	"net/http/httputil"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/catmorte/go-mdapi/internal/secrets"
 	"github.com/catmorte/go-mdapi/internal/vars"
 )
 
@@ -38,44 +41,42 @@ func (d internalHTTP) NewAPI() string {
 }
 
 func (d internalHTTP) Run(vrs vars.Vars) error {
-	requestURL, ok := InternalHTTPURLField.Get(vrs)
-	if !ok {
-		return errors.New("missing url field")
-	}
-
-	method, ok := InternalHTTPMethodField.Get(vrs)
-	if !ok {
-		method = "GET"
-	}
-
-	headers, err := d.headers(vrs)
+	rq, err := d.request(vrs)
 	if err != nil {
 		return err
 	}
+	_, err = httpSend(vrs, rq)
+	return err
+}
 
-	requestBody, contentType, err := d.buildRequestBody(vrs)
+// httpSend sends the request with the client, cookies and auth of the vars,
+// writes the status, headers, body and response.json and returns the body.
+func httpSend(vrs vars.Vars, rq *http.Request) ([]byte, error) {
+	client, err := httpClient(vrs)
 	if err != nil {
-		return err
-	}
-	if requestBody == nil {
-		requestBody = http.NoBody
+		return nil, err
 	}
 
-	predefinedContentType := headers.Get("Content-Type")
-	if len(predefinedContentType) == 0 && contentType != "" {
-		headers.Set("Content-Type", contentType)
+	jar, jarPath, err := httpCookieJar(vrs)
+	if err != nil {
+		return nil, fmt.Errorf("error loading cookies: %w", err)
+	}
+	if jar != nil {
+		client.Jar = jar
 	}
 
-	rq, err := http.NewRequest(method, requestURL, requestBody)
+	err = httpAuth(vrs, rq, client)
 	if err != nil {
-		return fmt.Errorf("error creating request: %w", err)
+		return nil, fmt.Errorf("error authenticating request: %w", err)
 	}
 
-	rq.Header = headers
+	recorder := newHTTPRecorder()
+	recorder.wrap(client)
+	rq = recorder.trace(rq)
 
-	resp, err := http.DefaultClient.Do(rq)
+	resp, err := client.Do(rq)
 	if err != nil {
-		return fmt.Errorf("error making request: %w", err)
+		return nil, fmt.Errorf("error making request: %w", err)
 	}
 
 	defer resp.Body.Close()
@@ -84,11 +85,10 @@ func (d internalHTTP) Run(vrs vars.Vars) error {
 
 	statusFile := filepath.Join(resultDir, "status")
 	headersFile := filepath.Join(resultDir, "headers")
-	bodyFile := filepath.Join(resultDir, "body")
 
-	err = os.WriteFile(statusFile, []byte(resp.Status), 0x775)
+	err = os.WriteFile(statusFile, []byte(resp.Status), 0o644)
 	if err != nil {
-		return fmt.Errorf("error writing status: %w", err)
+		return nil, fmt.Errorf("error writing status: %w", err)
 	}
 
 	sb := strings.Builder{}
@@ -97,38 +97,102 @@ func (d internalHTTP) Run(vrs vars.Vars) error {
 			sb.WriteString(fmt.Sprintf("%s: %s\n", key, value))
 		}
 	}
-	err = os.WriteFile(headersFile, []byte(sb.String()), 0x775)
+	err = os.WriteFile(headersFile, []byte(sb.String()), 0o644)
 	if err != nil {
-		return fmt.Errorf("error writing headers: %w", err)
+		return nil, fmt.Errorf("error writing headers: %w", err)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := httpReadBody(vrs, resp, resultDir)
 	if err != nil {
-		return fmt.Errorf("error reading body: %w", err)
+		return nil, err
 	}
-	err = os.WriteFile(bodyFile, body, 0x775)
+
+	err = recorder.write(resultDir, resp, int64(len(body)))
 	if err != nil {
-		return fmt.Errorf("error writing body: %w", err)
+		return nil, fmt.Errorf("error writing response: %w", err)
 	}
 
-	return nil
+	if jar != nil {
+		err = jar.Save(jarPath)
+		if err != nil {
+			return nil, fmt.Errorf("error saving cookies: %w", err)
+		}
+	}
+
+	return body, nil
+}
+
+func (d internalHTTP) request(vrs vars.Vars) (*http.Request, error) {
+	requestURL, ok := InternalHTTPURLField.Get(vrs)
+	if !ok {
+		return nil, errors.New("missing url field")
+	}
+
+	method, ok := InternalHTTPMethodField.Get(vrs)
+	if !ok {
+		method = "GET"
+	}
+
+	headers, err := d.headers(vrs)
+	if err != nil {
+		return nil, err
+	}
+	if headers == nil {
+		headers = http.Header{}
+	}
+
+	requestBody, contentType, err := d.buildRequestBody(vrs)
+	if err != nil {
+		return nil, err
+	}
+	if requestBody == nil {
+		requestBody = http.NoBody
+	}
+
+	predefinedContentType := headers.Get("Content-Type")
+	if len(predefinedContentType) == 0 && contentType != "" {
+		headers.Set("Content-Type", contentType)
+	}
+
+	rq, err := http.NewRequest(method, requestURL, requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	rq.Header = headers
+	return rq, nil
 }
 
 func (d internalHTTP) headers(vrs vars.Vars) (http.Header, error) {
 	var headers http.Header
 	headersRaw, ok := InternalHTTPHeadersField.Get(vrs)
 	if ok {
-		headersLines := strings.Split(headersRaw, "\n")
-		for _, line := range headersLines {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) != 2 {
-				return nil, fmt.Errorf("invalid header line: %s", line)
-			}
-			if headers == nil {
-				headers = http.Header{}
-			}
-			headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+		lines, err := ParseHeaders(headersRaw)
+		if err != nil {
+			return nil, err
+		}
+		headers = http.Header{}
+		for _, h := range lines {
+			headers.Add(h.Name, h.Value)
+		}
+	}
+	return headers, nil
+}
+
+type Header struct {
+	Name  string
+	Value string
+}
+
+// ParseHeaders reads the "Name: value" lines of the headers field in order.
+func ParseHeaders(raw string) ([]Header, error) {
+	headers := []Header{}
+	for _, line := range strings.Split(raw, "\n") {
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header line: %s", line)
 		}
+		headers = append(headers, Header{Name: strings.TrimSpace(key), Value: strings.TrimSpace(val)})
 	}
 	return headers, nil
 }
@@ -150,38 +214,29 @@ func (d internalHTTP) buildRequestBody(vrs vars.Vars) (io.Reader, string, error)
 		bodyBuf := &bytes.Buffer{}
 		writer := multipart.NewWriter(bodyBuf)
 
-		lines := strings.Split(form, "\n")
-		for _, line := range lines {
-			parts := strings.SplitN(line, ":", 2)
-			if len(parts) != 2 {
-				return nil, "", fmt.Errorf("invalid form line: %s", line)
-			}
-
-			key := strings.TrimSpace(parts[0])
-			val := strings.TrimSpace(parts[1])
-
-			if strings.HasPrefix(key, "@") {
-				filePath := strings.TrimPrefix(key, "@")
-				file, err := os.Open(filePath)
-				if err != nil {
-					return nil, "", fmt.Errorf("failed to open form file %q: %w", filePath, err)
-				}
-				defer file.Close()
-
-				part, err := writer.CreateFormFile("file", filepath.Base(filePath))
-				if err != nil {
-					return nil, "", fmt.Errorf("failed to create form file part: %w", err)
-				}
-				if _, err := io.Copy(part, file); err != nil {
-					return nil, "", fmt.Errorf("failed to copy form file: %w", err)
-				}
-			} else {
-				if strings.HasPrefix(val, "\\@") {
-					val = strings.TrimPrefix(val, "\\")
-				}
-				if err := writer.WriteField(key, val); err != nil {
+		parts, err := ParseForm(form)
+		if err != nil {
+			return nil, "", err
+		}
+		for _, p := range parts {
+			if !p.File {
+				if err := writer.WriteField(p.Name, p.Value); err != nil {
 					return nil, "", fmt.Errorf("failed to write form field: %w", err)
 				}
+				continue
+			}
+			file, err := os.Open(p.Value)
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to open form file %q: %w", p.Value, err)
+			}
+			defer file.Close()
+
+			part, err := writer.CreateFormFile(p.Name, filepath.Base(p.Value))
+			if err != nil {
+				return nil, "", fmt.Errorf("failed to create form file part: %w", err)
+			}
+			if _, err := io.Copy(part, file); err != nil {
+				return nil, "", fmt.Errorf("failed to copy form file: %w", err)
 			}
 		}
 
@@ -195,18 +250,81 @@ func (d internalHTTP) buildRequestBody(vrs vars.Vars) (io.Reader, string, error)
 	return nil, "", nil
 }
 
+// FormPart is a line of the form field: "name: value" is a field,
+// "name: @path" a file sent as the name part and "@path: ..." a file sent as
+// the file part. A value starting with @ is kept as text when escaped as \@.
+type FormPart struct {
+	Name  string
+	Value string
+	File  bool
+}
+
+func ParseForm(form string) ([]FormPart, error) {
+	parts := []FormPart{}
+	for _, line := range strings.Split(form, "\n") {
+		key, val, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid form line: %s", line)
+		}
+		key = strings.TrimSpace(key)
+		val = strings.TrimSpace(val)
+		switch {
+		case strings.HasPrefix(key, "@"):
+			parts = append(parts, FormPart{Name: "file", Value: strings.TrimPrefix(key, "@"), File: true})
+		case strings.HasPrefix(val, "@"):
+			parts = append(parts, FormPart{Name: key, Value: strings.TrimPrefix(val, "@"), File: true})
+		default:
+			if strings.HasPrefix(val, "\\@") {
+				val = strings.TrimPrefix(val, "\\")
+			}
+			parts = append(parts, FormPart{Name: key, Value: val})
+		}
+	}
+	return parts, nil
+}
+
 func (d internalHTTP) Compile(vrs vars.Vars) error {
-	fmt.Println("not supported for internal commands")
+	rq, err := d.request(vrs)
+	if err != nil {
+		return err
+	}
+	return httpDump(vrs, rq)
+}
+
+// httpDump prints the request as it would be sent, with cookies and auth
+// except the oauth2 token which is only fetched when running.
+func httpDump(vrs vars.Vars, rq *http.Request) error {
+	if rq.Body != nil {
+		defer rq.Body.Close()
+	}
+	jar, _, err := httpCookieJar(vrs)
+	if err != nil {
+		return fmt.Errorf("error loading cookies: %w", err)
+	}
+	if jar != nil {
+		for _, c := range jar.Cookies(rq.URL) {
+			rq.AddCookie(c)
+		}
+	}
+	err = httpAuth(vrs, rq, nil)
+	if err != nil {
+		return fmt.Errorf("error authenticating request: %w", err)
+	}
+	raw, err := httputil.DumpRequestOut(rq, true)
+	if err != nil {
+		return fmt.Errorf("error dumping request: %w", err)
+	}
+	fmt.Println(secrets.Redact(string(raw)))
 	return nil
 }
 
 func (d internalHTTP) GetVars() []string {
-	return []string{
+	return append([]string{
 		string(InternalHTTPMethodField),
 		string(InternalHTTPURLField),
 		string(InternalHTTPBodyField),
 		string(InternalHTTPBodyFileField),
 		string(InternalHTTPHeadersField),
 		string(InternalHTTPFormField),
-	}
+	}, slices.Concat(httpClientVars(), httpCookiesVars(), httpAuthVars(), httpStreamVars())...)
 }
@@ -0,0 +1,157 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/catmorte/go-mdapi/internal/auth"
+	"github.com/catmorte/go-mdapi/internal/secrets"
+	"github.com/catmorte/go-mdapi/internal/vars"
+)
+
+const (
+	InternalHTTPAuthField              FieldVar = "auth"
+	InternalHTTPAuthUserField          FieldVar = "authUser"
+	InternalHTTPAuthPasswordField      FieldVar = "authPassword"
+	InternalHTTPAuthTokenField         FieldVar = "authToken"
+	InternalHTTPOAuthTokenURLField     FieldVar = "oauthTokenURL"
+	InternalHTTPOAuthClientIDField     FieldVar = "oauthClientID"
+	InternalHTTPOAuthClientSecretField FieldVar = "oauthClientSecret"
+	InternalHTTPOAuthScopeField        FieldVar = "oauthScope"
+	InternalHTTPOAuthGrantField        FieldVar = "oauthGrant"
+	InternalHTTPAWSAccessKeyField      FieldVar = "awsAccessKey"
+	InternalHTTPAWSSecretKeyField      FieldVar = "awsSecretKey"
+	InternalHTTPAWSSessionTokenField   FieldVar = "awsSessionToken"
+	InternalHTTPAWSRegionField         FieldVar = "awsRegion"
+	InternalHTTPAWSServiceField        FieldVar = "awsService"
+
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthOAuth2 = "oauth2"
+	AuthAWSV4  = "awsv4"
+)
+
+func httpAuthVars() []string {
+	return []string{
+		string(InternalHTTPAuthField),
+		string(InternalHTTPAuthUserField),
+		string(InternalHTTPAuthPasswordField),
+		string(InternalHTTPAuthTokenField),
+		string(InternalHTTPOAuthTokenURLField),
+		string(InternalHTTPOAuthClientIDField),
+		string(InternalHTTPOAuthClientSecretField),
+		string(InternalHTTPOAuthScopeField),
+		string(InternalHTTPOAuthGrantField),
+		string(InternalHTTPAWSAccessKeyField),
+		string(InternalHTTPAWSSecretKeyField),
+		string(InternalHTTPAWSSessionTokenField),
+		string(InternalHTTPAWSRegionField),
+		string(InternalHTTPAWSServiceField),
+	}
+}
+
+func (f FieldVar) GetTrimmed(vrs vars.Vars) string {
+	v, _ := f.Get(vrs)
+	return strings.TrimSpace(v)
+}
+
+// OAuth2Placeholder is shown instead of the token when the request is only
+// compiled or exported.
+const OAuth2Placeholder = "<oauth2>"
+
+// HTTPAuth is the auth field with the credentials of its kind.
+type HTTPAuth struct {
+	Kind     string
+	User     string
+	Password string
+	Token    string
+	OAuth2   auth.OAuth2
+	AWS      auth.AWSCredentials
+	Region   string
+	Service  string
+}
+
+// ParseHTTPAuth reads the auth fields, credentials are registered as secrets
+// so they don't end up in .vars and outputs.
+func ParseHTTPAuth(vrs vars.Vars) (HTTPAuth, error) {
+	for _, f := range []FieldVar{
+		InternalHTTPAuthPasswordField,
+		InternalHTTPAuthTokenField,
+		InternalHTTPOAuthClientSecretField,
+		InternalHTTPAWSSecretKeyField,
+		InternalHTTPAWSSessionTokenField,
+	} {
+		secrets.Add(f.GetTrimmed(vrs))
+	}
+
+	a := HTTPAuth{Kind: strings.ToLower(InternalHTTPAuthField.GetTrimmed(vrs))}
+	switch a.Kind {
+	case "":
+	case AuthBasic:
+		a.User = InternalHTTPAuthUserField.GetTrimmed(vrs)
+		a.Password = InternalHTTPAuthPasswordField.GetTrimmed(vrs)
+		// the header is as good as the password
+		secrets.Add(auth.BasicCredentials(a.User, a.Password))
+	case AuthBearer:
+		a.Token = InternalHTTPAuthTokenField.GetTrimmed(vrs)
+		if a.Token == "" {
+			return a, fmt.Errorf("missing %s field", InternalHTTPAuthTokenField)
+		}
+	case AuthOAuth2:
+		a.OAuth2 = auth.OAuth2{
+			TokenURL:     InternalHTTPOAuthTokenURLField.GetTrimmed(vrs),
+			ClientID:     InternalHTTPOAuthClientIDField.GetTrimmed(vrs),
+			ClientSecret: InternalHTTPOAuthClientSecretField.GetTrimmed(vrs),
+			Scope:        InternalHTTPOAuthScopeField.GetTrimmed(vrs),
+			Grant:        InternalHTTPOAuthGrantField.GetTrimmed(vrs),
+			Username:     InternalHTTPAuthUserField.GetTrimmed(vrs),
+			Password:     InternalHTTPAuthPasswordField.GetTrimmed(vrs),
+			CacheDir:     filepath.Join(filepath.Dir(vrs.GetResultDir()), ".oauth"),
+		}
+	case AuthAWSV4:
+		a.AWS = auth.AWSCredentials{
+			AccessKey:    InternalHTTPAWSAccessKeyField.GetTrimmed(vrs),
+			SecretKey:    InternalHTTPAWSSecretKeyField.GetTrimmed(vrs),
+			SessionToken: InternalHTTPAWSSessionTokenField.GetTrimmed(vrs),
+		}
+		a.Region = InternalHTTPAWSRegionField.GetTrimmed(vrs)
+		a.Service = InternalHTTPAWSServiceField.GetTrimmed(vrs)
+	default:
+		return a, fmt.Errorf("unknown auth %s, supported: %s", a.Kind, strings.Join([]string{AuthBasic, AuthBearer, AuthOAuth2, AuthAWSV4}, ", "))
+	}
+	return a, nil
+}
+
+// httpAuth signs the request according to the auth fields. Without a client
+// nothing is fetched over the network, an oauth2 token is replaced by a
+// placeholder.
+func httpAuth(vrs vars.Vars, rq *http.Request, client *http.Client) error {
+	a, err := ParseHTTPAuth(vrs)
+	if err != nil {
+		return err
+	}
+	switch a.Kind {
+	case AuthBasic:
+		auth.Basic(rq, a.User, a.Password)
+	case AuthBearer:
+		auth.Bearer(rq, a.Token)
+	case AuthOAuth2:
+		if client == nil {
+			auth.Bearer(rq, OAuth2Placeholder)
+			return nil
+		}
+		a.OAuth2.Client = client
+		t, err := a.OAuth2.Token(rq.Context())
+		if err != nil {
+			return err
+		}
+		secrets.Add(t.AccessToken)
+		auth.Bearer(rq, t.AccessToken)
+	case AuthAWSV4:
+		return auth.SignV4(rq, a.AWS, a.Region, a.Service, time.Now())
+	}
+	return nil
+}
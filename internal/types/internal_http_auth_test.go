@@ -0,0 +1,74 @@
+package types
+
+import (
+	"encoding/base64"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/catmorte/go-mdapi/internal/secrets"
+	"github.com/catmorte/go-mdapi/internal/vars"
+)
+
+// stdout returns what fn prints.
+func stdout(t *testing.T, fn func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := os.Stdout
+	os.Stdout = w
+	err = fn()
+	os.Stdout = orig
+	w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestHTTPCompileRedactsBasicAuth(t *testing.T) {
+	vrs := vars.Vars{
+		string(InternalHTTPURLField):          "http://localhost/users",
+		string(InternalHTTPAuthField):         AuthBasic,
+		string(InternalHTTPAuthUserField):     "bob",
+		string(InternalHTTPAuthPasswordField): "compile-pw",
+	}
+	vrs.SetResultDir(t.TempDir())
+
+	out := stdout(t, func() error {
+		return internalHTTPTemplate.Compile(vrs)
+	})
+	encoded := base64.StdEncoding.EncodeToString([]byte("bob:compile-pw"))
+	if strings.Contains(out, encoded) || strings.Contains(out, "compile-pw") {
+		t.Errorf("compile output has the credentials:\n%s", out)
+	}
+	if !strings.Contains(out, "Authorization: Basic "+secrets.Mask) {
+		t.Errorf("compile output has no redacted auth header:\n%s", out)
+	}
+}
+
+func TestHTTPCompileOAuth2Placeholder(t *testing.T) {
+	vrs := vars.Vars{
+		string(InternalHTTPURLField):               "http://localhost/users",
+		string(InternalHTTPAuthField):              AuthOAuth2,
+		string(InternalHTTPOAuthTokenURLField):     "http://127.0.0.1:1/token",
+		string(InternalHTTPOAuthClientIDField):     "client",
+		string(InternalHTTPOAuthClientSecretField): "compile-secret",
+	}
+	vrs.SetResultDir(t.TempDir())
+
+	// the token url isn't reachable, compile must not call it
+	out := stdout(t, func() error {
+		return internalHTTPTemplate.Compile(vrs)
+	})
+	if !strings.Contains(out, "Authorization: Bearer "+OAuth2Placeholder) {
+		t.Errorf("compile output has no oauth2 placeholder:\n%s", out)
+	}
+}
@@ -0,0 +1,161 @@
+package types
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/catmorte/go-mdapi/internal/vars"
+)
+
+const (
+	InternalHTTPTimeoutField         FieldVar = "timeout"
+	InternalHTTPCACertField          FieldVar = "cacert"
+	InternalHTTPCertField            FieldVar = "cert"
+	InternalHTTPKeyField             FieldVar = "key"
+	InternalHTTPInsecureField        FieldVar = "insecure"
+	InternalHTTPProxyField           FieldVar = "proxy"
+	InternalHTTPFollowRedirectsField FieldVar = "followRedirects"
+)
+
+func (f FieldVar) GetBool(vrs vars.Vars, def bool) (bool, error) {
+	v, ok := f.Get(vrs)
+	if !ok || strings.TrimSpace(v) == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return def, fmt.Errorf("invalid %s field: %w", f, err)
+	}
+	return b, nil
+}
+
+func (f FieldVar) GetInt(vrs vars.Vars, def int) (int, error) {
+	v, ok := f.Get(vrs)
+	if !ok || strings.TrimSpace(v) == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return def, fmt.Errorf("invalid %s field: %w", f, err)
+	}
+	return n, nil
+}
+
+// GetDuration accepts go durations (1m30s) or a number of seconds.
+func (f FieldVar) GetDuration(vrs vars.Vars) (time.Duration, error) {
+	v, ok := f.Get(vrs)
+	v = strings.TrimSpace(v)
+	if !ok || v == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s field: %w", f, err)
+	}
+	return d, nil
+}
+
+func httpClientVars() []string {
+	return []string{
+		string(InternalHTTPTimeoutField),
+		string(InternalHTTPCACertField),
+		string(InternalHTTPCertField),
+		string(InternalHTTPKeyField),
+		string(InternalHTTPInsecureField),
+		string(InternalHTTPProxyField),
+		string(InternalHTTPFollowRedirectsField),
+	}
+}
+
+func httpClient(vrs vars.Vars) (*http.Client, error) {
+	timeout, err := InternalHTTPTimeoutField.GetDuration(vrs)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := httpTLSConfig(vrs)
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	if proxy, ok := InternalHTTPProxyField.Get(vrs); ok && strings.TrimSpace(proxy) != "" {
+		proxyURL, err := url.Parse(strings.TrimSpace(proxy))
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy field: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}
+
+	followRedirects, err := InternalHTTPFollowRedirectsField.GetBool(vrs, true)
+	if err != nil {
+		return nil, err
+	}
+	if !followRedirects {
+		client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+
+	return client, nil
+}
+
+func httpTLSConfig(vrs vars.Vars) (*tls.Config, error) {
+	insecure, err := InternalHTTPInsecureField.GetBool(vrs, false)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+
+	if caCert, ok := InternalHTTPCACertField.Get(vrs); ok && strings.TrimSpace(caCert) != "" {
+		pem, err := os.ReadFile(strings.TrimSpace(caCert))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cacert: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in cacert %s", caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	cert, hasCert := InternalHTTPCertField.Get(vrs)
+	key, hasKey := InternalHTTPKeyField.Get(vrs)
+	cert = strings.TrimSpace(cert)
+	key = strings.TrimSpace(key)
+	hasCert = hasCert && cert != ""
+	hasKey = hasKey && key != ""
+	if hasCert != hasKey {
+		return nil, errors.New("cert and key fields must be set together")
+	}
+	if hasCert {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	return tlsConfig, nil
+}
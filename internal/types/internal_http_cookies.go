@@ -0,0 +1,46 @@
+package types
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/cookies"
+	"github.com/catmorte/go-mdapi/internal/vars"
+)
+
+const (
+	InternalHTTPCookiesField   FieldVar = "cookies"
+	InternalHTTPCookieJarField FieldVar = "cookieJar"
+)
+
+func httpCookiesVars() []string {
+	return []string{
+		string(InternalHTTPCookiesField),
+		string(InternalHTTPCookieJarField),
+	}
+}
+
+// httpCookieJar returns nil when cookies are disabled. Setting a cookieJar
+// name enables them and shares the jar between all the files using the name,
+// otherwise the jar is per file.
+func httpCookieJar(vrs vars.Vars) (*cookies.Jar, string, error) {
+	name, hasName := InternalHTTPCookieJarField.Get(vrs)
+	name = strings.TrimSpace(name)
+	hasName = hasName && name != ""
+	enabled, err := InternalHTTPCookiesField.GetBool(vrs, hasName)
+	if err != nil {
+		return nil, "", err
+	}
+	if !enabled {
+		return nil, "", nil
+	}
+	if !hasName {
+		name = vrs.GetCurrentFile()
+	}
+	path := cookies.Path(filepath.Dir(vrs.GetResultDir()), name)
+	jar, err := cookies.Load(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return jar, path, nil
+}
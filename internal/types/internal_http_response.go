@@ -0,0 +1,189 @@
+package types
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const responseFile = "response.json"
+
+type (
+	httpRedirect struct {
+		URL        string `json:"url"`
+		StatusCode int    `json:"statusCode"`
+	}
+	httpCert struct {
+		Subject  string    `json:"subject"`
+		Issuer   string    `json:"issuer"`
+		NotAfter time.Time `json:"notAfter"`
+	}
+	httpTLS struct {
+		Version     string     `json:"version"`
+		CipherSuite string     `json:"cipherSuite"`
+		ServerName  string     `json:"serverName"`
+		Protocol    string     `json:"protocol,omitempty"`
+		PeerCerts   []httpCert `json:"peerCertificates"`
+	}
+	httpTimings struct {
+		DNS     float64 `json:"dnsMs"`
+		Connect float64 `json:"connectMs"`
+		TLS     float64 `json:"tlsMs"`
+		TTFB    float64 `json:"ttfbMs"`
+		Total   float64 `json:"totalMs"`
+	}
+	httpResponse struct {
+		Status        string              `json:"status"`
+		StatusCode    int                 `json:"statusCode"`
+		Proto         string              `json:"proto"`
+		Headers       map[string][]string `json:"headers"`
+		URL           string              `json:"url"`
+		Redirects     []httpRedirect      `json:"redirects"`
+		ContentLength int64               `json:"contentLength"`
+		BodySize      int64               `json:"bodySize"`
+		TLS           *httpTLS            `json:"tls,omitempty"`
+		Timings       httpTimings         `json:"timings"`
+	}
+
+	// httpRecorder collects redirects and phase timings of a request,
+	// phases are summed up over all the requests of a redirect chain.
+	httpRecorder struct {
+		mu        sync.Mutex
+		started   time.Time
+		firstByte time.Time
+		dnsStart  time.Time
+		connStart time.Time
+		tlsStart  time.Time
+		dns       time.Duration
+		connect   time.Duration
+		tls       time.Duration
+		redirects []httpRedirect
+	}
+)
+
+func newHTTPRecorder() *httpRecorder {
+	return &httpRecorder{started: time.Now()}
+}
+
+func (r *httpRecorder) trace(rq *http.Request) *http.Request {
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.dns += time.Since(r.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.connStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.connect += time.Since(r.connStart)
+		},
+		TLSHandshakeStart: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.tls += time.Since(r.tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.firstByte = time.Now()
+		},
+	}
+	return rq.WithContext(httptrace.WithClientTrace(rq.Context(), trace))
+}
+
+func (r *httpRecorder) wrap(client *http.Client) {
+	next := client.CheckRedirect
+	client.CheckRedirect = func(rq *http.Request, via []*http.Request) error {
+		if next != nil {
+			if err := next(rq, via); err != nil {
+				return err
+			}
+		} else if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		redirect := httpRedirect{URL: via[len(via)-1].URL.String()}
+		if rq.Response != nil {
+			redirect.StatusCode = rq.Response.StatusCode
+		}
+		r.redirects = append(r.redirects, redirect)
+		return nil
+	}
+}
+
+func (r *httpRecorder) write(resultDir string, resp *http.Response, bodySize int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	total := time.Since(r.started)
+	ttfb := time.Duration(0)
+	if !r.firstByte.IsZero() {
+		ttfb = r.firstByte.Sub(r.started)
+	}
+	res := httpResponse{
+		Status:        resp.Status,
+		StatusCode:    resp.StatusCode,
+		Proto:         resp.Proto,
+		Headers:       resp.Header,
+		URL:           resp.Request.URL.String(),
+		Redirects:     r.redirects,
+		ContentLength: resp.ContentLength,
+		BodySize:      bodySize,
+		Timings: httpTimings{
+			DNS:     ms(r.dns),
+			Connect: ms(r.connect),
+			TLS:     ms(r.tls),
+			TTFB:    ms(ttfb),
+			Total:   ms(total),
+		},
+	}
+	if res.Redirects == nil {
+		res.Redirects = []httpRedirect{}
+	}
+	if resp.TLS != nil {
+		res.TLS = &httpTLS{
+			Version:     tls.VersionName(resp.TLS.Version),
+			CipherSuite: tls.CipherSuiteName(resp.TLS.CipherSuite),
+			ServerName:  resp.TLS.ServerName,
+			Protocol:    resp.TLS.NegotiatedProtocol,
+			PeerCerts:   []httpCert{},
+		}
+		for _, c := range resp.TLS.PeerCertificates {
+			res.TLS.PeerCerts = append(res.TLS.PeerCerts, httpCert{
+				Subject:  c.Subject.String(),
+				Issuer:   c.Issuer.String(),
+				NotAfter: c.NotAfter,
+			})
+		}
+	}
+	raw, err := json.MarshalIndent(res, "", " ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(resultDir, responseFile), raw, 0o644)
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,272 @@
+package types
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/catmorte/go-mdapi/internal/vars"
+)
+
+const (
+	InternalHTTPStreamField      FieldVar = "stream"
+	InternalHTTPSSEField         FieldVar = "sse"
+	InternalHTTPMaxEventsField   FieldVar = "maxEvents"
+	InternalHTTPMaxDurationField FieldVar = "maxDuration"
+)
+
+const sseContentType = "text/event-stream"
+
+func httpStreamVars() []string {
+	return []string{
+		string(InternalHTTPStreamField),
+		string(InternalHTTPSSEField),
+		string(InternalHTTPMaxEventsField),
+		string(InternalHTTPMaxDurationField),
+	}
+}
+
+type sseEvent struct {
+	Time  string `json:"time"`
+	ID    string `json:"id,omitempty"`
+	Event string `json:"event"`
+	Data  string `json:"data"`
+	Retry int    `json:"retry,omitempty"`
+}
+
+// httpReadBody writes the response body to the body file. Event streams and
+// responses with the stream field set are written as they come in, until
+// maxEvents events (lines unless it's sse) or maxDuration, the sse events
+// are written to events.jsonl as well.
+func httpReadBody(vrs vars.Vars, resp *http.Response, resultDir string) ([]byte, error) {
+	bodyFile := filepath.Join(resultDir, "body")
+	eventsFile := filepath.Join(resultDir, "events.jsonl")
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	isSSE := mediaType == sseContentType
+	stream, err := InternalHTTPStreamField.GetBool(vrs, isSSE)
+	if err != nil {
+		return nil, err
+	}
+	sse, err := InternalHTTPSSEField.GetBool(vrs, isSSE)
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(eventsFile)
+
+	if !stream {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("error reading body: %w", err)
+		}
+		err = os.WriteFile(bodyFile, body, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("error writing body: %w", err)
+		}
+		if sse {
+			return body, writeSSEEvents(eventsFile, body)
+		}
+		return body, nil
+	}
+
+	maxEvents, err := InternalHTTPMaxEventsField.GetInt(vrs, 0)
+	if err != nil {
+		return nil, err
+	}
+	maxDuration, err := InternalHTTPMaxDurationField.GetDuration(vrs)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := os.OpenFile(bodyFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error writing body: %w", err)
+	}
+	defer out.Close()
+
+	var events *os.File
+	if sse {
+		events, err = os.OpenFile(eventsFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("error writing events: %w", err)
+		}
+		defer events.Close()
+	}
+
+	// closing the body is the only way to interrupt a blocked read
+	stopped := atomic.Bool{}
+	if maxDuration > 0 {
+		timer := time.AfterFunc(maxDuration, func() {
+			stopped.Store(true)
+			resp.Body.Close()
+		})
+		defer timer.Stop()
+	}
+
+	body := bytes.Buffer{}
+	parser := sseParser{}
+	count := 0
+	line := []byte{}
+	buf := make([]byte, 32*1024)
+	for maxEvents <= 0 || count < maxEvents {
+		n, readErr := resp.Body.Read(buf)
+		chunk := buf[:n]
+		if n > 0 {
+			if !sse && maxEvents > 0 {
+				// cut the chunk at the last line still to count
+				chunk, count = cutLines(chunk, count, maxEvents)
+			}
+			body.Write(chunk)
+			_, err = out.Write(chunk)
+			if err != nil {
+				return nil, fmt.Errorf("error writing body: %w", err)
+			}
+		}
+		if sse {
+			line = append(line, chunk...)
+			for {
+				i := bytes.IndexByte(line, '\n')
+				if i < 0 {
+					break
+				}
+				ev, ok := parser.feed(strings.TrimSuffix(string(line[:i]), "\r"))
+				line = line[i+1:]
+				if !ok {
+					continue
+				}
+				err = writeSSEEvent(events, ev)
+				if err != nil {
+					return nil, err
+				}
+				count++
+				if maxEvents > 0 && count >= maxEvents {
+					break
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			if stopped.Load() || isTimeout(readErr) {
+				break
+			}
+			return nil, fmt.Errorf("error reading body: %w", readErr)
+		}
+	}
+	return body.Bytes(), nil
+}
+
+// cutLines counts the lines of the chunk and cuts it after the max line.
+func cutLines(chunk []byte, count, max int) ([]byte, int) {
+	for i, c := range chunk {
+		if c != '\n' {
+			continue
+		}
+		count++
+		if count >= max {
+			return chunk[:i+1], count
+		}
+	}
+	return chunk, count
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func writeSSEEvents(eventsFile string, body []byte) error {
+	events, err := os.OpenFile(eventsFile, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing events: %w", err)
+	}
+	defer events.Close()
+	parser := sseParser{}
+	lines := strings.Split(strings.ReplaceAll(string(body), "\r\n", "\n"), "\n")
+	for _, l := range append(lines, "") {
+		ev, ok := parser.feed(l)
+		if !ok {
+			continue
+		}
+		err = writeSSEEvent(events, ev)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSSEEvent(w io.Writer, ev sseEvent) error {
+	raw, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %w", err)
+	}
+	_, err = w.Write(append(raw, '\n'))
+	if err != nil {
+		return fmt.Errorf("error writing events: %w", err)
+	}
+	return nil
+}
+
+// sseParser follows the event stream format of the html spec: fields are
+// collected until an empty line dispatches the event, the id is kept for
+// the next events.
+type sseParser struct {
+	id    string
+	event string
+	data  []string
+	retry int
+}
+
+func (p *sseParser) feed(line string) (sseEvent, bool) {
+	if line == "" {
+		if p.data == nil {
+			p.event = ""
+			return sseEvent{}, false
+		}
+		ev := sseEvent{
+			Time:  time.Now().Format(time.RFC3339Nano),
+			ID:    p.id,
+			Event: p.event,
+			Data:  strings.Join(p.data, "\n"),
+			Retry: p.retry,
+		}
+		if ev.Event == "" {
+			ev.Event = "message"
+		}
+		p.event, p.data, p.retry = "", nil, 0
+		return ev, true
+	}
+	if strings.HasPrefix(line, ":") {
+		return sseEvent{}, false
+	}
+	field, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+	switch field {
+	case "event":
+		p.event = value
+	case "data":
+		p.data = append(p.data, value)
+	case "id":
+		if !strings.ContainsRune(value, 0) {
+			p.id = value
+		}
+	case "retry":
+		if retry, err := strconv.Atoi(value); err == nil {
+			p.retry = retry
+		}
+	}
+	return sseEvent{}, false
+}
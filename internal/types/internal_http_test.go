@@ -0,0 +1,98 @@
+package types
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/catmorte/go-mdapi/internal/vars"
+)
+
+func TestParseForm(t *testing.T) {
+	tests := []struct {
+		name string
+		form string
+		want []FormPart
+	}{
+		{"field", "name: bob", []FormPart{{Name: "name", Value: "bob"}}},
+		{"file part", "@avatar.png: ignored", []FormPart{{Name: "file", Value: "avatar.png", File: true}}},
+		{"file part without name", "@avatar.png:", []FormPart{{Name: "file", Value: "avatar.png", File: true}}},
+		{"named file", "avatar: @avatar.png", []FormPart{{Name: "avatar", Value: "avatar.png", File: true}}},
+		{"escaped value", `handle: \@bob`, []FormPart{{Name: "handle", Value: "@bob"}}},
+		{"backslash kept", `path: \dir`, []FormPart{{Name: "path", Value: `\dir`}}},
+		{"value with colon", "url: http://localhost", []FormPart{{Name: "url", Value: "http://localhost"}}},
+		{"several", "name: bob\n@a.txt:\ndoc: @b.txt", []FormPart{
+			{Name: "name", Value: "bob"},
+			{Name: "file", Value: "a.txt", File: true},
+			{Name: "doc", Value: "b.txt", File: true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseForm(tt.form)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseForm() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseForm("no colon"); err == nil {
+		t.Error("expected an error for a line without colon")
+	}
+}
+
+func TestHTTPFormParts(t *testing.T) {
+	parts := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		for {
+			p, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			raw, _ := io.ReadAll(p)
+			parts[p.FormName()] = p.FileName() + "=" + string(raw)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "aaa", "b.txt": "bbb"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	vrs := vars.Vars{
+		string(InternalHTTPMethodField): http.MethodPost,
+		string(InternalHTTPURLField):    srv.URL,
+		string(InternalHTTPFormField): strings.Join([]string{
+			"name: bob",
+			"@" + filepath.Join(dir, "a.txt") + ": ignored",
+			"doc: @" + filepath.Join(dir, "b.txt"),
+		}, "\n"),
+	}
+	vrs.SetResultDir(t.TempDir())
+	if err := internalHTTPTemplate.Run(vrs); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"name": "=bob", "file": "a.txt=aaa", "doc": "b.txt=bbb"}
+	if !reflect.DeepEqual(parts, want) {
+		t.Errorf("parts = %v, want %v", parts, want)
+	}
+}
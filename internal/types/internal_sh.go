@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/catmorte/go-mdapi/internal/command"
+	"github.com/catmorte/go-mdapi/internal/secrets"
 	"github.com/catmorte/go-mdapi/internal/vars"
 )
 
@@ -40,7 +41,7 @@ func (d internalSh) Run(vrs vars.Vars) error {
 	if err != nil {
 		return fmt.Errorf("error reading body: %s", err)
 	}
-	err = os.WriteFile(bodyFile, []byte(body), 0x775)
+	err = os.WriteFile(bodyFile, []byte(body), 0o644)
 	if err != nil {
 		return fmt.Errorf("error writing body: %w", err)
 	}
@@ -49,7 +50,11 @@ func (d internalSh) Run(vrs vars.Vars) error {
 }
 
 func (d internalSh) Compile(vrs vars.Vars) error {
-	fmt.Println("not supported for internal commands")
+	script, ok := InternalSHScriptField.Get(vrs)
+	if !ok {
+		return fmt.Errorf("missing script field")
+	}
+	fmt.Println(secrets.Redact(script))
 	return nil
 }
 
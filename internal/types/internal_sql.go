@@ -0,0 +1,364 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	_ "embed"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/catmorte/go-mdapi/internal/secrets"
+	"github.com/catmorte/go-mdapi/internal/vars"
+	_ "modernc.org/sqlite"
+)
+
+type internalSQL string
+
+const (
+	InternalSQLDriverField FieldVar = "driver"
+	InternalSQLDSNField    FieldVar = "dsn"
+	InternalSQLQueryField  FieldVar = "query"
+	InternalSQLParamsField FieldVar = "params"
+)
+
+const sqlDefaultDriver = "sqlite"
+
+// statements which return rows, the others are executed to get the number of
+// affected rows
+var sqlRowsQuery = regexp.MustCompile(`(?is)^\s*(select|with|pragma|values|explain|show|describe)\b|\breturning\b`)
+
+//go:embed internal_sql_new_api.md
+var internalSQLTemplate internalSQL
+
+type sqlResult struct {
+	Driver       string   `json:"driver"`
+	Columns      []string `json:"columns,omitempty"`
+	Rows         int      `json:"rows"`
+	RowsAffected *int64   `json:"rowsAffected,omitempty"`
+	LastInsertID *int64   `json:"lastInsertId,omitempty"`
+	Duration     string   `json:"duration"`
+}
+
+func (d internalSQL) GetName() string {
+	return "sql"
+}
+
+func (d internalSQL) NewAPI() string {
+	return string(internalSQLTemplate)
+}
+
+// Run executes the query and writes the rows to body (json) and body.csv,
+// the number of rows or affected rows to status and the details to
+// result.json.
+func (d internalSQL) Run(vrs vars.Vars) error {
+	driver, dsn, query, params, err := d.request(vrs)
+	if err != nil {
+		return err
+	}
+	timeout, err := InternalHTTPTimeoutField.GetDuration(vrs)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return fmt.Errorf("error opening database: %w", err)
+	}
+	defer db.Close()
+
+	res := sqlResult{Driver: driver}
+	columns := []string{}
+	rows := [][]any{}
+	status := ""
+	start := time.Now()
+	if sqlRowsQuery.MatchString(query) {
+		columns, rows, err = sqlQuery(ctx, db, query, params)
+		if err != nil {
+			return err
+		}
+		res.Columns = columns
+		res.Rows = len(rows)
+		status = fmt.Sprintf("%d rows", len(rows))
+	} else {
+		result, err := db.ExecContext(ctx, query, params...)
+		if err != nil {
+			return fmt.Errorf("error executing query: %w", err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			res.RowsAffected = &n
+			status = fmt.Sprintf("%d rows affected", n)
+		}
+		if id, err := result.LastInsertId(); err == nil {
+			res.LastInsertID = &id
+		}
+	}
+	res.Duration = time.Since(start).String()
+
+	resultDir := vrs.GetResultDir()
+	err = os.WriteFile(filepath.Join(resultDir, "status"), []byte(status), 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing status: %w", err)
+	}
+	raw, err := json.MarshalIndent(res, "", " ")
+	if err != nil {
+		return fmt.Errorf("error encoding result: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(resultDir, "result.json"), raw, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing result: %w", err)
+	}
+	// without rows the body is the result so after can take the affected
+	// rows and the inserted id
+	body := raw
+	if res.Columns != nil {
+		body, err = sqlJSON(columns, rows)
+		if err != nil {
+			return err
+		}
+	}
+	err = os.WriteFile(filepath.Join(resultDir, "body"), body, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing body: %w", err)
+	}
+	csvBody, err := sqlCSV(columns, rows)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(filepath.Join(resultDir, "body.csv"), csvBody, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+	return nil
+}
+
+func sqlQuery(ctx context.Context, db *sql.DB, query string, params []any) ([]string, [][]any, error) {
+	rs, err := db.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error executing query: %w", err)
+	}
+	defer rs.Close()
+	columns, err := rs.Columns()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading columns: %w", err)
+	}
+	rows := [][]any{}
+	for rs.Next() {
+		row := make([]any, len(columns))
+		ptrs := make([]any, len(columns))
+		for i := range row {
+			ptrs[i] = &row[i]
+		}
+		err = rs.Scan(ptrs...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading row: %w", err)
+		}
+		for i, v := range row {
+			row[i] = sqlValue(v)
+		}
+		rows = append(rows, row)
+	}
+	err = rs.Err()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading rows: %w", err)
+	}
+	return columns, rows, nil
+}
+
+// sqlValue makes the scanned value printable, blobs which aren't text are
+// base64 encoded.
+func sqlValue(v any) any {
+	switch v := v.(type) {
+	case []byte:
+		if utf8.Valid(v) {
+			return string(v)
+		}
+		return base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+func sqlJSON(columns []string, rows [][]any) ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString("[")
+	for i, row := range rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		// written by hand to keep the order of the columns
+		buf.WriteString("\n  {")
+		for j, col := range columns {
+			if j > 0 {
+				buf.WriteString(", ")
+			}
+			key, _ := json.Marshal(col)
+			val, err := json.Marshal(row[j])
+			if err != nil {
+				return nil, fmt.Errorf("error encoding %s: %w", col, err)
+			}
+			buf.Write(key)
+			buf.WriteString(": ")
+			buf.Write(val)
+		}
+		buf.WriteString("}")
+	}
+	if len(rows) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	return buf.Bytes(), nil
+}
+
+func sqlCSV(columns []string, rows [][]any) ([]byte, error) {
+	buf := bytes.Buffer{}
+	w := csv.NewWriter(&buf)
+	if len(columns) > 0 {
+		w.Write(columns)
+	}
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			switch v := v.(type) {
+			case nil:
+			case string:
+				record[i] = v
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("error writing csv: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (d internalSQL) request(vrs vars.Vars) (string, string, string, []any, error) {
+	driver, _ := InternalSQLDriverField.Get(vrs)
+	driver = strings.TrimSpace(driver)
+	if driver == "" {
+		driver = sqlDefaultDriver
+	}
+	if !slices.Contains(sql.Drivers(), driver) {
+		return "", "", "", nil, fmt.Errorf("unsupported driver %s, available: %s", driver, strings.Join(sql.Drivers(), ", "))
+	}
+	dsn, ok := InternalSQLDSNField.Get(vrs)
+	if !ok || strings.TrimSpace(dsn) == "" {
+		return "", "", "", nil, errors.New("missing dsn field")
+	}
+	query, ok := InternalSQLQueryField.Get(vrs)
+	if !ok || strings.TrimSpace(query) == "" {
+		return "", "", "", nil, errors.New("missing query field")
+	}
+	params, err := d.params(vrs)
+	if err != nil {
+		return "", "", "", nil, err
+	}
+	return driver, strings.TrimSpace(dsn), query, params, nil
+}
+
+// params reads a json array of positional params (? or $1) or a json object
+// of named params (:name, @name or $name).
+func (d internalSQL) params(vrs vars.Vars) ([]any, error) {
+	raw, ok := InternalSQLParamsField.Get(vrs)
+	raw = strings.TrimSpace(raw)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	err := dec.Decode(&v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid params field: %w", err)
+	}
+	params := []any{}
+	switch v := v.(type) {
+	case []any:
+		for _, p := range v {
+			params = append(params, sqlParam(p))
+		}
+	case map[string]any:
+		names := make([]string, 0, len(v))
+		for k := range v {
+			names = append(names, k)
+		}
+		slices.Sort(names)
+		for _, k := range names {
+			params = append(params, sql.Named(k, sqlParam(v[k])))
+		}
+	default:
+		return nil, errors.New("invalid params field: expected a json array or object")
+	}
+	return params, nil
+}
+
+func sqlParam(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case []any, map[string]any:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	}
+	return v
+}
+
+func (d internalSQL) Compile(vrs vars.Vars) error {
+	driver, dsn, query, params, err := d.request(vrs)
+	if err != nil {
+		return err
+	}
+	sb := strings.Builder{}
+	sb.WriteString(driver + " " + dsn + "\n\n")
+	sb.WriteString(strings.TrimSpace(query) + "\n")
+	if len(params) > 0 {
+		sb.WriteString("\n")
+	}
+	for i, p := range params {
+		if named, ok := p.(sql.NamedArg); ok {
+			sb.WriteString(fmt.Sprintf(":%s = %v\n", named.Name, named.Value))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("$%d = %v\n", i+1, p))
+	}
+	fmt.Print(secrets.Redact(sb.String()))
+	return nil
+}
+
+func (d internalSQL) GetVars() []string {
+	return []string{
+		string(InternalSQLDriverField),
+		string(InternalSQLDSNField),
+		string(InternalSQLQueryField),
+		string(InternalSQLParamsField),
+		string(InternalHTTPTimeoutField),
+	}
+}
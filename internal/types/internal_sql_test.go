@@ -0,0 +1,117 @@
+package types
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/catmorte/go-mdapi/internal/vars"
+)
+
+func sqlRun(t *testing.T, dsn, query, params string) vars.Vars {
+	t.Helper()
+	vrs := vars.Vars{
+		string(InternalSQLDSNField):   dsn,
+		string(InternalSQLQueryField): query,
+	}
+	if params != "" {
+		vrs[string(InternalSQLParamsField)] = params
+	}
+	vrs.SetResultDir(t.TempDir())
+	if err := internalSQLTemplate.Run(vrs); err != nil {
+		t.Fatal(err)
+	}
+	return vrs
+}
+
+func TestSQLExec(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	sqlRun(t, dsn, "create table users (id integer primary key, name text, age integer)", "")
+
+	vrs := sqlRun(t, dsn, "insert into users (name, age) values (?, ?), (?, ?)", `["bob", 30, "alice", 25]`)
+	if got := readResult(t, vrs, "status"); got != "2 rows affected" {
+		t.Errorf("status = %q", got)
+	}
+	// the body of an exec is the result
+	res := sqlResult{}
+	if err := json.Unmarshal([]byte(readResult(t, vrs, "body")), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected == nil || *res.RowsAffected != 2 {
+		t.Errorf("rowsAffected = %v, want 2", res.RowsAffected)
+	}
+	if res.LastInsertID == nil || *res.LastInsertID != 2 {
+		t.Errorf("lastInsertId = %v, want 2", res.LastInsertID)
+	}
+	if got := readResult(t, vrs, "result.json"); got != readResult(t, vrs, "body") {
+		t.Errorf("result.json = %q, expected the body", got)
+	}
+
+	vrs = sqlRun(t, dsn, "update users set age = age + 1 where name = :name", `{"name": "bob"}`)
+	if got := readResult(t, vrs, "status"); got != "1 rows affected" {
+		t.Errorf("status = %q", got)
+	}
+}
+
+func TestSQLQuery(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	sqlRun(t, dsn, "create table users (id integer primary key, name text, age integer, note text)", "")
+	sqlRun(t, dsn, "insert into users (name, age, note) values ('bob', 30, 'a, \"b\"'), ('alice', 25, null)", "")
+
+	vrs := sqlRun(t, dsn, "select name, age, note from users where age > ? order by id", `[20]`)
+	if got := readResult(t, vrs, "status"); got != "2 rows" {
+		t.Errorf("status = %q", got)
+	}
+
+	// the columns keep the order of the query
+	wantBody := `[
+  {"name": "bob", "age": 30, "note": "a, \"b\""},
+  {"name": "alice", "age": 25, "note": null}
+]
+`
+	if got := readResult(t, vrs, "body"); got != wantBody {
+		t.Errorf("body:\n%s\nwant:\n%s", got, wantBody)
+	}
+	wantCSV := "name,age,note\nbob,30,\"a, \"\"b\"\"\"\nalice,25,\n"
+	if got := readResult(t, vrs, "body.csv"); got != wantCSV {
+		t.Errorf("body.csv:\n%s\nwant:\n%s", got, wantCSV)
+	}
+
+	res := sqlResult{}
+	if err := json.Unmarshal([]byte(readResult(t, vrs, "result.json")), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res.Rows != 2 || len(res.Columns) != 3 || res.RowsAffected != nil {
+		t.Errorf("result = %+v", res)
+	}
+
+	vrs = sqlRun(t, dsn, "select name from users where age > 100", "")
+	if got := readResult(t, vrs, "body"); got != "[]\n" {
+		t.Errorf("body = %q, want an empty array", got)
+	}
+	if got := readResult(t, vrs, "body.csv"); got != "name\n" {
+		t.Errorf("body.csv = %q, want the header only", got)
+	}
+}
+
+func TestSQLInvalid(t *testing.T) {
+	dsn := filepath.Join(t.TempDir(), "test.db")
+	tests := []struct {
+		name string
+		vrs  vars.Vars
+	}{
+		{"driver", vars.Vars{string(InternalSQLDriverField): "nope", string(InternalSQLDSNField): dsn, string(InternalSQLQueryField): "select 1"}},
+		{"dsn", vars.Vars{string(InternalSQLQueryField): "select 1"}},
+		{"query", vars.Vars{string(InternalSQLDSNField): dsn}},
+		{"params", vars.Vars{string(InternalSQLDSNField): dsn, string(InternalSQLQueryField): "select ?", string(InternalSQLParamsField): "1"}},
+		{"syntax", vars.Vars{string(InternalSQLDSNField): dsn, string(InternalSQLQueryField): "selec 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.vrs.SetResultDir(t.TempDir())
+			if err := internalSQLTemplate.Run(tt.vrs); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
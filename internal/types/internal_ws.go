@@ -0,0 +1,243 @@
+package types
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/catmorte/go-mdapi/internal/secrets"
+	"github.com/catmorte/go-mdapi/internal/vars"
+	"github.com/coder/websocket"
+)
+
+type internalWS string
+
+const (
+	InternalWSMessagesField FieldVar = "messages"
+	InternalWSWaitField     FieldVar = "wait"
+	InternalWSCountField    FieldVar = "count"
+
+	wsDefaultWait = time.Second
+)
+
+//go:embed internal_ws_new_api.md
+var internalWSTemplate internalWS
+
+type wsTranscript struct {
+	mu       sync.Mutex
+	lines    []string
+	received []json.RawMessage
+}
+
+func (t *wsTranscript) add(direction string, typ websocket.MessageType, payload []byte) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	text := strings.ReplaceAll(string(payload), "\n", "\\n")
+	if typ == websocket.MessageBinary {
+		text = "[binary] " + base64.StdEncoding.EncodeToString(payload)
+	}
+	t.lines = append(t.lines, fmt.Sprintf("%s %s %s", time.Now().Format(time.RFC3339Nano), direction, text))
+	if direction == "<" {
+		raw := json.RawMessage(payload)
+		if typ == websocket.MessageBinary || !json.Valid(payload) {
+			raw, _ = json.Marshal(text)
+		}
+		t.received = append(t.received, raw)
+	}
+	return len(t.received)
+}
+
+func (d internalWS) GetName() string {
+	return "ws"
+}
+
+func (d internalWS) NewAPI() string {
+	return string(internalWSTemplate)
+}
+
+// Run sends the messages (one per line) in order and keeps receiving until
+// count messages arrived or nothing came for the wait duration after the
+// last send. The transcript has a line per message: time, > (sent) or <
+// (received) and the payload, body is a json array of the received ones.
+func (d internalWS) Run(vrs vars.Vars) error {
+	rawURL, headers, messages, err := d.request(vrs)
+	if err != nil {
+		return err
+	}
+	wait, err := InternalWSWaitField.GetDuration(vrs)
+	if err != nil {
+		return err
+	}
+	if wait <= 0 {
+		wait = wsDefaultWait
+	}
+	count, err := InternalWSCountField.GetInt(vrs, 0)
+	if err != nil {
+		return err
+	}
+	timeout, err := InternalHTTPTimeoutField.GetDuration(vrs)
+	if err != nil {
+		return err
+	}
+	client, err := httpClient(vrs)
+	if err != nil {
+		return err
+	}
+	// the connection outlives the handshake, the timeout is applied to the
+	// whole session instead
+	client.Timeout = 0
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+	defer cancel()
+
+	conn, resp, err := websocket.Dial(ctx, rawURL, &websocket.DialOptions{HTTPClient: client, HTTPHeader: headers})
+	if err != nil {
+		return fmt.Errorf("error connecting: %w", err)
+	}
+	defer conn.CloseNow()
+
+	transcript := &wsTranscript{}
+	received := make(chan int)
+	readErr := make(chan error, 1)
+	go func() {
+		for {
+			typ, payload, err := conn.Read(ctx)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case received <- transcript.add("<", typ, payload):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for _, msg := range messages {
+		err = conn.Write(ctx, websocket.MessageText, []byte(msg))
+		if err != nil {
+			return fmt.Errorf("error sending message: %w", err)
+		}
+		transcript.add(">", websocket.MessageText, []byte(msg))
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+loop:
+	for {
+		select {
+		case n := <-received:
+			if count > 0 && n >= count {
+				break loop
+			}
+			timer.Reset(wait)
+		case err = <-readErr:
+			if websocket.CloseStatus(err) == -1 && ctx.Err() == nil {
+				return fmt.Errorf("error receiving message: %w", err)
+			}
+			break loop
+		case <-timer.C:
+			break loop
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	conn.Close(websocket.StatusNormalClosure, "")
+
+	return d.write(vrs.GetResultDir(), resp, transcript)
+}
+
+func (d internalWS) write(resultDir string, resp *http.Response, transcript *wsTranscript) error {
+	transcript.mu.Lock()
+	defer transcript.mu.Unlock()
+
+	err := os.WriteFile(filepath.Join(resultDir, "status"), []byte(resp.Status), 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing status: %w", err)
+	}
+	sb := strings.Builder{}
+	for key, values := range resp.Header {
+		for _, value := range values {
+			sb.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+		}
+	}
+	err = os.WriteFile(filepath.Join(resultDir, "headers"), []byte(sb.String()), 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing headers: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(resultDir, "transcript"), []byte(strings.Join(transcript.lines, "\n")), 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing transcript: %w", err)
+	}
+	body, err := json.MarshalIndent(transcript.received, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error converting messages to json: %w", err)
+	}
+	err = os.WriteFile(filepath.Join(resultDir, "body"), body, 0o644)
+	if err != nil {
+		return fmt.Errorf("error writing body: %w", err)
+	}
+	return nil
+}
+
+func (d internalWS) request(vrs vars.Vars) (string, http.Header, []string, error) {
+	rawURL, ok := InternalHTTPURLField.Get(vrs)
+	if !ok {
+		return "", nil, nil, errors.New("missing url field")
+	}
+	headers, err := internalHTTPTemplate.headers(vrs)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	raw, _ := InternalWSMessagesField.Get(vrs)
+	messages := []string{}
+	for _, line := range strings.Split(raw, "\n") {
+		if strings.TrimSpace(line) != "" {
+			messages = append(messages, line)
+		}
+	}
+	return strings.TrimSpace(rawURL), headers, messages, nil
+}
+
+func (d internalWS) Compile(vrs vars.Vars) error {
+	rawURL, headers, messages, err := d.request(vrs)
+	if err != nil {
+		return err
+	}
+	sb := strings.Builder{}
+	sb.WriteString("GET " + rawURL + "\n")
+	for key, values := range headers {
+		for _, value := range values {
+			sb.WriteString(fmt.Sprintf("%s: %s\n", key, value))
+		}
+	}
+	sb.WriteString("\n")
+	for _, msg := range messages {
+		sb.WriteString("> " + msg + "\n")
+	}
+	fmt.Print(secrets.Redact(sb.String()))
+	return nil
+}
+
+func (d internalWS) GetVars() []string {
+	return append([]string{
+		string(InternalHTTPURLField),
+		string(InternalHTTPHeadersField),
+		string(InternalWSMessagesField),
+		string(InternalWSWaitField),
+		string(InternalWSCountField),
+	}, httpClientVars()...)
+}
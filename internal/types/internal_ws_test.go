@@ -0,0 +1,146 @@
+package types
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/catmorte/go-mdapi/internal/vars"
+	"github.com/coder/websocket"
+)
+
+// wsServer sends the greetings on connect and echoes every message.
+func wsServer(t *testing.T, greetings ...string) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.CloseNow()
+		ctx := context.Background()
+		for _, g := range greetings {
+			if err := conn.Write(ctx, websocket.MessageText, []byte(g)); err != nil {
+				return
+			}
+		}
+		for {
+			typ, payload, err := conn.Read(ctx)
+			if err != nil {
+				return
+			}
+			if err := conn.Write(ctx, typ, payload); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func wsRun(t *testing.T, vrs vars.Vars) ([]json.RawMessage, time.Duration) {
+	t.Helper()
+	vrs.SetResultDir(t.TempDir())
+	started := time.Now()
+	if err := internalWSTemplate.Run(vrs); err != nil {
+		t.Fatal(err)
+	}
+	elapsed := time.Since(started)
+	received := []json.RawMessage{}
+	if err := json.Unmarshal([]byte(readResult(t, vrs, "body")), &received); err != nil {
+		t.Fatal(err)
+	}
+	return received, elapsed
+}
+
+func compactJSON(t *testing.T, raw json.RawMessage) string {
+	t.Helper()
+	buf := bytes.Buffer{}
+	if err := json.Compact(&buf, raw); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestWSEcho(t *testing.T) {
+	vrs := vars.Vars{
+		string(InternalHTTPURLField):    wsServer(t),
+		string(InternalWSMessagesField): "{\"type\": \"ping\"}\nhello",
+		string(InternalWSWaitField):     "200ms",
+	}
+	received, _ := wsRun(t, vrs)
+	// json messages are kept as json, the others are strings
+	if len(received) != 2 || compactJSON(t, received[0]) != `{"type":"ping"}` || string(received[1]) != `"hello"` {
+		t.Errorf("received = %s", received)
+	}
+	if got := readResult(t, vrs, "status"); got != "101 Switching Protocols" {
+		t.Errorf("status = %q", got)
+	}
+	// time, direction and payload, the echoes may come before the last send
+	sent, got := []string{}, []string{}
+	for _, line := range strings.Split(readResult(t, vrs, "transcript"), "\n") {
+		_, msg, _ := strings.Cut(line, " ")
+		if strings.HasPrefix(msg, "> ") {
+			sent = append(sent, msg)
+		} else {
+			got = append(got, msg)
+		}
+	}
+	if !slices.Equal(sent, []string{`> {"type": "ping"}`, "> hello"}) {
+		t.Errorf("sent = %q", sent)
+	}
+	if !slices.Equal(got, []string{`< {"type": "ping"}`, "< hello"}) {
+		t.Errorf("received = %q", got)
+	}
+}
+
+func TestWSCount(t *testing.T) {
+	url := wsServer(t, "1", "2", "3", "4")
+	// the count ends the session before the wait runs out
+	received, elapsed := wsRun(t, vars.Vars{
+		string(InternalHTTPURLField): url,
+		string(InternalWSCountField): "2",
+		string(InternalWSWaitField):  "10s",
+	})
+	if len(received) != 2 {
+		t.Errorf("received = %s, want 2 messages", received)
+	}
+	if elapsed > 5*time.Second {
+		t.Errorf("took %s, expected the count to end the session", elapsed)
+	}
+}
+
+func TestWSCountNotReached(t *testing.T) {
+	// the server sends less than the count, the wait ends the session
+	received, elapsed := wsRun(t, vars.Vars{
+		string(InternalHTTPURLField): wsServer(t, "1"),
+		string(InternalWSCountField): "3",
+		string(InternalWSWaitField):  "200ms",
+	})
+	if len(received) != 1 {
+		t.Errorf("received = %s, want 1 message", received)
+	}
+	if elapsed < 200*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("took %s, expected the wait to end the session", elapsed)
+	}
+}
+
+func TestWSIgnoresUndeclaredFields(t *testing.T) {
+	// count is a var of the file, not a field of the type section
+	vrs := vars.Vars{
+		string(InternalHTTPURLField): wsServer(t, "1", "2", "3"),
+		string(InternalWSWaitField):  "200ms",
+		"count":                      "1",
+	}
+	vrs.SetTypeFields([]string{string(InternalHTTPURLField), string(InternalWSWaitField)})
+	received, _ := wsRun(t, vrs)
+	if len(received) != 3 {
+		t.Errorf("received = %s, want the 3 messages", received)
+	}
+}
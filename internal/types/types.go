@@ -111,5 +111,9 @@ func InternalTypes() []DefinedType {
 	return []DefinedType{
 		internalHTTPTemplate,
 		internalShTemplate,
+		internalGRPCTemplate,
+		internalGraphQLTemplate,
+		internalWSTemplate,
+		internalSQLTemplate,
 	}
 }
@@ -0,0 +1,266 @@
+package vars
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/catmorte/go-mdapi/internal/converters"
+)
+
+const defaultFunc = "default"
+
+type (
+	call struct {
+		name string
+		args []string
+	}
+	// placeholder is a parsed {{name | func "arg" | ...}} expression, the head
+	// is either a var name or a quoted literal.
+	placeholder struct {
+		head    string
+		literal bool
+		calls   []call
+	}
+)
+
+// Render replaces placeholders in a single left to right pass. Placeholders
+// which can't be parsed or reference an unknown var without a default are
+// kept as is.
+func Render(text string, allFields map[string]string) (string, error) {
+	sb := strings.Builder{}
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			sb.WriteString(text)
+			return sb.String(), nil
+		}
+		end := strings.Index(text[start+2:], "}}")
+		if end < 0 {
+			sb.WriteString(text)
+			return sb.String(), nil
+		}
+		end += start + 2
+		sb.WriteString(text[:start])
+		raw := text[start : end+2]
+		text = text[end+2:]
+
+		p, ok := parsePlaceholder(raw[2 : len(raw)-2])
+		if !ok {
+			sb.WriteString(raw)
+			continue
+		}
+		val, ok, err := p.eval(allFields)
+		if err != nil {
+			return "", fmt.Errorf("failed to render %s: %w", raw, err)
+		}
+		if !ok {
+			sb.WriteString(raw)
+			continue
+		}
+		sb.WriteString(val)
+	}
+}
+
+// References returns the var names used by the placeholders of the text in
+// order of appearance without duplicates.
+func References(text string) []string {
+	refs := []string{}
+	seen := map[string]bool{}
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			return refs
+		}
+		end := strings.Index(text[start+2:], "}}")
+		if end < 0 {
+			return refs
+		}
+		p, ok := parsePlaceholder(text[start+2 : start+2+end])
+		text = text[start+2+end+2:]
+		if !ok || p.literal || seen[p.head] {
+			continue
+		}
+		seen[p.head] = true
+		refs = append(refs, p.head)
+	}
+}
+
+// HasDefault reports whether every placeholder in text referencing name
+// falls back to a default value.
+func HasDefault(text, name string) bool {
+	found := false
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			return found
+		}
+		end := strings.Index(text[start+2:], "}}")
+		if end < 0 {
+			return found
+		}
+		p, ok := parsePlaceholder(text[start+2 : start+2+end])
+		text = text[start+2+end+2:]
+		if !ok || p.literal || p.head != name {
+			continue
+		}
+		if !p.hasDefault() {
+			return false
+		}
+		found = true
+	}
+}
+
+func (p placeholder) hasDefault() bool {
+	for _, c := range p.calls {
+		if c.name == defaultFunc {
+			return true
+		}
+	}
+	return false
+}
+
+func (p placeholder) eval(allFields map[string]string) (string, bool, error) {
+	val := p.head
+	if !p.literal {
+		var found bool
+		val, found = allFields[p.head]
+		if !found && !p.hasDefault() {
+			return "", false, nil
+		}
+	}
+	for _, c := range p.calls {
+		if c.name == defaultFunc {
+			if len(c.args) != 1 {
+				return "", false, fmt.Errorf("%s expects 1 argument", defaultFunc)
+			}
+			if val == "" {
+				val = c.args[0]
+			}
+			continue
+		}
+		if len(c.args) != 0 {
+			return "", false, fmt.Errorf("%s expects no arguments", c.name)
+		}
+		var err error
+		val, err = converters.Convert(val, []string{c.name})
+		if err != nil {
+			return "", false, err
+		}
+	}
+	return val, true, nil
+}
+
+func parsePlaceholder(s string) (placeholder, bool) {
+	tokens, ok := tokenize(s)
+	if !ok || len(tokens) == 0 {
+		return placeholder{}, false
+	}
+	p := placeholder{}
+	head := tokens[0]
+	switch {
+	case isQuoted(head):
+		p.literal = true
+		p.head, ok = unquote(head)
+		if !ok {
+			return placeholder{}, false
+		}
+	case isIdent(head):
+		p.head = head
+	default:
+		return placeholder{}, false
+	}
+	tokens = tokens[1:]
+	for len(tokens) > 0 {
+		if tokens[0] != "|" || len(tokens) < 2 || !isIdent(tokens[1]) {
+			return placeholder{}, false
+		}
+		c := call{name: tokens[1]}
+		tokens = tokens[2:]
+		for len(tokens) > 0 && tokens[0] != "|" {
+			arg, ok := unquote(tokens[0])
+			if !ok {
+				return placeholder{}, false
+			}
+			c.args = append(c.args, arg)
+			tokens = tokens[1:]
+		}
+		p.calls = append(p.calls, c)
+	}
+	return p, true
+}
+
+func tokenize(s string) ([]string, bool) {
+	tokens := []string{}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '|':
+			tokens = append(tokens, "|")
+			i++
+		case c == '"' || c == '`' || c == '\'':
+			end := i + 1
+			for ; end < len(s); end++ {
+				if s[end] == '\\' && c == '"' {
+					end++
+					continue
+				}
+				if s[end] == c {
+					break
+				}
+			}
+			if end >= len(s) {
+				return nil, false
+			}
+			tokens = append(tokens, s[i:end+1])
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && isIdentChar(s[end]) {
+				end++
+			}
+			if end == i {
+				return nil, false
+			}
+			tokens = append(tokens, s[i:end])
+			i = end
+		}
+	}
+	return tokens, true
+}
+
+func isIdentChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_'
+}
+
+func isIdent(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isIdentChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && strings.ContainsRune("\"`'", rune(s[0])) && s[len(s)-1] == s[0]
+}
+
+func unquote(s string) (string, bool) {
+	if !isQuoted(s) {
+		return "", false
+	}
+	if s[0] == '\'' {
+		return s[1 : len(s)-1], true
+	}
+	res, err := strconv.Unquote(s)
+	if err != nil {
+		return "", false
+	}
+	return res, true
+}
@@ -0,0 +1,123 @@
+package vars
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	fields := map[string]string{
+		"name":  "Bob",
+		"empty": "",
+		"host":  "localhost",
+		"quote": `say "hi"`,
+		"tmpl":  "{{name}}",
+	}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"plain", "hello {{name}}", "hello Bob"},
+		{"spaces", "{{ name }}", "Bob"},
+		{"several", "{{host}}/users/{{name}}", "localhost/users/Bob"},
+		{"no placeholder", "plain text", "plain text"},
+		{"filter", "{{name | upper}}", "BOB"},
+		{"pipeline", "{{name | lower | base64}}", "Ym9i"},
+		{"literal", `{{"a b" | urlencode}}`, "a+b"},
+		{"single quoted literal", `{{'it' | upper}}`, "IT"},
+		{"backtick literal", "{{`x` | upper}}", "X"},
+		{"default of missing", `{{missing | default "guest"}}`, "guest"},
+		{"default of empty", `{{empty | default "guest"}}`, "guest"},
+		{"default of set", `{{name | default "guest"}}`, "Bob"},
+		{"filter after default", `{{missing | default "guest" | upper}}`, "GUEST"},
+		{"default with pipe", `{{missing | default "a|b"}}`, "a|b"},
+		{"default with escaped quote", `{{missing | default "a\"b"}}`, `a"b`},
+		{"escaping", "{{quote | q2_escape}}", `say \"hi\"`},
+		{"missing kept", "{{missing}}", "{{missing}}"},
+		{"missing with filter kept", "{{missing | upper}}", "{{missing | upper}}"},
+		{"malformed kept", "{{a b}}", "{{a b}}"},
+		{"unterminated quote kept", `{{missing | default "x}}`, `{{missing | default "x}}`},
+		{"unterminated", "a {{name", "a {{name"},
+		{"unterminated after a placeholder", "{{name}} {{name", "Bob {{name"},
+		{"empty placeholder", "{{}}", "{{}}"},
+		// values aren't rendered again
+		{"single pass", "{{tmpl}}", "{{name}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.text, fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Render(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	fields := map[string]string{"name": "Bob", "bad": "%%"}
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"unknown filter", "{{name | nope}}", "unknown converter nope"},
+		{"filter with arguments", `{{name | upper "x"}}`, "upper expects no arguments"},
+		{"default without argument", "{{name | default}}", "default expects 1 argument"},
+		{"default with arguments", `{{name | default "a" "b"}}`, "default expects 1 argument"},
+		{"failing filter", "{{bad | base64decode}}", "illegal base64"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Render(tt.text, fields)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Render(%q) err = %v, want %q", tt.text, err, tt.want)
+			}
+		})
+	}
+
+	// ReplacePatterns keeps the text on errors
+	if got := ReplacePatterns("{{name | nope}}", fields); got != "{{name | nope}}" {
+		t.Errorf("ReplacePatterns() = %q", got)
+	}
+}
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"{{a}} {{b | upper}} {{a}}", []string{"a", "b"}},
+		{`{{"literal"}} {{c | default "x"}}`, []string{"c"}},
+		{"{{a b}} {{d", []string{}},
+		{"none", []string{}},
+	}
+	for _, tt := range tests {
+		if got := References(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("References(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestHasDefault(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{`{{a | default "x"}}`, true},
+		{`{{a | upper | default "x"}}`, true},
+		{"{{a}}", false},
+		{`{{a | default "x"}} {{a}}`, false},
+		{`{{b}} {{a | default "x"}}`, true},
+		{"no reference", false},
+	}
+	for _, tt := range tests {
+		if got := HasDefault(tt.text, "a"); got != tt.want {
+			t.Errorf("HasDefault(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -6,16 +6,18 @@ const (
 	currentDir  = "CURDIR"
 	currentFile = "CURFILE"
 	resultDir   = "RESULTDIR"
+	typeFields  = "TYPEFIELDS"
 )
 
 type Vars map[string]string
 
+// ReplacePatterns is Render which keeps the text as is on errors.
 func ReplacePatterns(text string, allFields map[string]string) string {
-	for variableName, value := range allFields {
-		placeholder := "{{" + variableName + "}}"
-		text = strings.ReplaceAll(text, placeholder, value)
+	res, err := Render(text, allFields)
+	if err != nil {
+		return text
 	}
-	return text
+	return res
 }
 
 func (v Vars) GetCurrentDir() string {
@@ -41,3 +43,24 @@ func (v Vars) SetCurrentFile(file string) {
 func (v Vars) SetResultDir(dir string) {
 	v[resultDir] = dir
 }
+
+// SetTypeFields keeps the names of the fields declared in the type section,
+// the types read their fields from those only.
+func (v Vars) SetTypeFields(names []string) {
+	v[typeFields] = strings.Join(names, "\n")
+}
+
+// IsTypeField tells if the name is declared in the type section, all names
+// are when the type fields weren't set.
+func (v Vars) IsTypeField(name string) bool {
+	names, ok := v[typeFields]
+	if !ok {
+		return true
+	}
+	for _, n := range strings.Split(names, "\n") {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
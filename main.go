@@ -1,32 +1,54 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
+	"io"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
+	"github.com/catmorte/go-mdapi/internal/assertions"
 	"github.com/catmorte/go-mdapi/internal/converters"
+	"github.com/catmorte/go-mdapi/internal/cookies"
+	"github.com/catmorte/go-mdapi/internal/exporter"
 	"github.com/catmorte/go-mdapi/internal/file"
+	"github.com/catmorte/go-mdapi/internal/importer"
+	"github.com/catmorte/go-mdapi/internal/lint"
 	"github.com/catmorte/go-mdapi/internal/parser"
+	"github.com/catmorte/go-mdapi/internal/prompt"
+	"github.com/catmorte/go-mdapi/internal/runner"
+	"github.com/catmorte/go-mdapi/internal/secrets"
+	"github.com/catmorte/go-mdapi/internal/session"
 	"github.com/catmorte/go-mdapi/internal/types"
 	varsPkg "github.com/catmorte/go-mdapi/internal/vars"
 	"github.com/spf13/cobra"
 )
 
 var (
-	mdPath  string
-	vars    = map[string]string{}
-	cfgPath string
+	mdPath   string
+	vars     = map[string]string{}
+	cfgPath  string
+	parallel int
+	cacheTTL time.Duration
+	envName  string
+	outDir   string
+	reqName  string
 
-	resultFolder = ".result"
+	interactive bool
+
+	clearSession bool
+	clearCookies bool
+	jarName      string
 )
 
 func assert(err error, s string, args ...any) {
 	if err != nil {
-		fmt.Println(fmt.Sprintf(s+": ", args...), err)
+		fmt.Println(fmt.Sprintf(s+": ", args...), secrets.Redact(err.Error()))
 		os.Exit(1)
 	}
 }
@@ -38,18 +60,24 @@ func assertOK(ok bool, s string, args ...any) {
 	}
 }
 
-func prepareVars() varsPkg.Vars {
-	curdir := filepath.Dir(mdPath)
-	curfile := strings.TrimSuffix(filepath.Base(mdPath), filepath.Ext(mdPath))
-	resdir := filepath.Join(curdir, resultFolder, curfile)
+func prepareVars() (varsPkg.Vars, runner.Sources) {
 	if vars == nil {
 		vars = map[string]string{}
 	}
-	allFields := varsPkg.Vars(vars)
-	allFields.SetCurrentDir(curdir)
-	allFields.SetCurrentFile(curfile)
-	allFields.SetResultDir(resdir)
-	return allFields
+	allFields, sources, err := newRunner().Vars(mdPath, vars)
+	assert(err, "failed to prepare vars")
+	return allFields, sources
+}
+
+func newRunner() *runner.Runner {
+	r := runner.New(cfgPath)
+	r.CacheTTL = cacheTTL
+	r.Env = envName
+	r.Name = reqName
+	if interactive {
+		r.Prompter = prompt.New(os.Stdin, os.Stderr)
+	}
+	return r
 }
 
 var rootCmd = &cobra.Command{
@@ -63,11 +91,27 @@ var rootCmd = &cobra.Command{
 		fmt.Println(" - CURFILE - current file w/o extension")
 		fmt.Println(" - RESULTDIR - result directory")
 		fmt.Println()
+		fmt.Println("vars are referenced as {{name}}, filters and defaults are piped: {{name | default \"x\" | upper}}")
+		fmt.Println()
+		fmt.Println("a file can declare several requests under # headings with their own ## type section,")
+		fmt.Println("the sections of the other headings are shared, run runs them in order unless --name is set")
+		fmt.Println()
 		fmt.Println("each var supports the following filters")
 		for _, v := range converters.SupportedConvs() {
 			fmt.Println(" - " + v)
 		}
 		fmt.Println()
+		fmt.Println("each var supports the following flags (### name:flag)")
+		for _, v := range file.GetSupportedFlags() {
+			d, _ := file.GetFlagDescription(v)
+			fmt.Println(" - " + v + " - " + d)
+		}
+		fmt.Println()
+		fmt.Println("assert section supports the following types")
+		for _, v := range assertions.GetSupportedTypes() {
+			fmt.Println(" - " + v)
+		}
+		fmt.Println()
 		fmt.Println("internal types")
 		for _, v := range types.InternalTypes() {
 			fmt.Println(" - " + v.GetName())
@@ -95,6 +139,25 @@ var varTypesCmd = &cobra.Command{
 	},
 }
 
+var assertTypesCmd = &cobra.Command{
+	Use:   "assert_types",
+	Short: "returns all available assert types",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		lenArgs := len(args)
+		switch lenArgs {
+		case 0:
+			for _, v := range assertions.GetSupportedTypes() {
+				fmt.Println(v)
+			}
+		default:
+			c, err := assertions.GetTypeDescription(args[0])
+			assert(err, "failed to get assert type description")
+			fmt.Println(c)
+		}
+	},
+}
+
 var typeVarsCmd = &cobra.Command{
 	Use:   "type_vars",
 	Short: "returns all possible type's vars",
@@ -136,17 +199,18 @@ var generateCmd = &cobra.Command{
 
 var varsCmd = &cobra.Command{
 	Use:   "vars [var_name] [index]",
-	Short: "shows all the vars in format name:type:count",
+	Short: "shows all the vars in format name:type:count:source",
 	Args:  cobra.RangeArgs(0, 2),
 	Run: func(cmd *cobra.Command, args []string) {
-		allFields := prepareVars()
-		fileData, err := parser.ParseMarkdownFile(mdPath, allFields)
+		allFields, sources := prepareVars()
+		fileData, err := parser.ParseRequest(mdPath, reqName, allFields)
 		assert(err, "failed to open file")
 		lenArgs := len(args)
 		switch lenArgs {
 		case 0:
+			fmt.Fprintln(os.Stderr, "precedence: "+sources.Precedence())
 			for _, v := range fileData.Vars {
-				fmt.Printf("%s:%s:%d", v.Nam, v.Typ, len(v.Vals))
+				fmt.Printf("%s:%s:%d:%s", v.Nam, v.Typ, len(v.Vals), sources.Get(v.Nam))
 				fmt.Println()
 			}
 		default:
@@ -171,17 +235,8 @@ var compileCmd = &cobra.Command{
 	Short: "compile the api",
 	Args:  cobra.MaximumNArgs(1), // Allow at most 1 argument
 	Run: func(cmd *cobra.Command, args []string) {
-		allFields := prepareVars()
-		fileData, err := parser.ParseMarkdownFile(mdPath, allFields)
+		_, allFields, dt, err := newRunner().Prepare(mdPath, vars)
 		assert(err, "failed to prepare")
-		allFields, err = fileData.Compute(vars)
-		assert(err, "failed to compute")
-		dts, err := types.GetDefinedTypes(cfgPath)
-		assert(err, "failed to get defined types")
-		dt, err := dts.FindByName(fileData.Typ.Typ)
-		assert(err, "failed to get defined type")
-		err = fileData.Typ.Fields.Compute(allFields, true)
-		assert(err, "failed to parse type fields")
 		err = dt.Compile(allFields)
 		assert(err, "failed to run")
 	},
@@ -189,55 +244,254 @@ var compileCmd = &cobra.Command{
 
 var runCmd = &cobra.Command{
 	Use:   "run",
-	Short: "run the api",
+	Short: "run the api (all the requests of the file in order if --name isn't set)",
 	Args:  cobra.MaximumNArgs(1), // Allow at most 1 argument
 	Run: func(cmd *cobra.Command, args []string) {
-		allFields := prepareVars()
-		fileData, err := parser.ParseMarkdownFile(mdPath, allFields)
-		assert(err, "failed to prepare")
-		resdir := allFields.GetResultDir()
-		curdir := allFields.GetCurrentDir()
-		curfile := allFields.GetCurrentFile()
-		allFields, err = fileData.Compute(vars)
-		assert(err, "failed to compute")
-		dts, err := types.GetDefinedTypes(cfgPath)
-		assert(err, "failed to get defined types")
-		dt, err := dts.FindByName(fileData.Typ.Typ)
-		assert(err, "failed to get defined type")
-		err = fileData.Typ.Fields.Compute(allFields, true)
-		assert(err, "failed to parse type fields")
-		_, err = os.Stat(resdir)
-		if !os.IsNotExist(err) {
-			counter := 1
-			var newPath string
-			for {
-				newPath = filepath.Join(curdir, resultFolder, fmt.Sprintf("%s_%d", curfile, counter))
-				_, err = os.Stat(newPath)
-				if os.IsNotExist(err) {
-					err = os.Rename(resdir, newPath)
-					assert(err, "failed to rename")
-					break
+		for _, res := range newRunner().RunFile(mdPath, vars) {
+			assert(res.Err, "failed to run %s", res.Path)
+			fmt.Println(res.ResultDir)
+			if len(res.Asserts) == 0 {
+				continue
+			}
+			assertOK(!res.Asserts.Failed(), "assertions failed:\n%s", secrets.Redact(res.Asserts.Report()))
+		}
+	},
+}
+
+var runAllCmd = &cobra.Command{
+	Use:   "run-all [dir]",
+	Short: "run all the apis found in the directory tree",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		paths, err := runner.Collect(dir)
+		assert(err, "failed to collect apis")
+		results := newRunner().RunAll(paths, vars, parallel)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "STATUS\tFILE\tDURATION\tRESULT")
+		failed := 0
+		for _, res := range results {
+			status := "PASS"
+			details := res.ResultDir
+			if res.Failed() {
+				status = "FAIL"
+				failed++
+			}
+			if res.Err != nil {
+				details = secrets.Redact(res.Err.Error())
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status, res.Path, res.Duration.Round(time.Millisecond), details)
+		}
+		w.Flush()
+		for _, res := range results {
+			if res.Asserts.Failed() {
+				fmt.Println()
+				fmt.Println(res.Path)
+				fmt.Print(secrets.Redact(res.Asserts.Report()))
+			}
+		}
+		assertOK(failed == 0, "%d of %d failed", failed, len(results))
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <" + strings.Join(importer.Sources(), "|") + "> <command|file|->",
+	Short: "convert a curl command, a postman collection or an openapi 3 spec into api files",
+	Args:  cobra.MatchAll(cobra.ExactArgs(2), firstArgOneOf(importer.Sources())),
+	Run: func(cmd *cobra.Command, args []string) {
+		var raw []byte
+		var err error
+		switch {
+		case args[1] == "-":
+			raw, err = io.ReadAll(os.Stdin)
+			assert(err, "failed to read stdin")
+		case args[0] == "curl":
+			raw = []byte(args[1])
+		default:
+			raw, err = os.ReadFile(args[1])
+			assert(err, "failed to read %s", args[1])
+		}
+		apis, err := importer.Import(args[0], raw)
+		assert(err, "failed to import")
+		if outDir == "" && len(apis) == 1 {
+			fmt.Print(apis[0].Markdown())
+			return
+		}
+		if outDir == "" {
+			outDir = "."
+		}
+		written, err := importer.Write(apis, outDir)
+		for _, v := range written {
+			fmt.Println(v)
+		}
+		assert(err, "failed to write")
+	},
+}
+
+var exportCmd = &cobra.Command{
+	Use:   "export <" + strings.Join(exporter.Formats(), "|") + "> [dir]",
+	Short: "export the http api (or all the http apis in the directory for postman) to another client",
+	Args:  cobra.MatchAll(cobra.RangeArgs(1, 2), firstArgOneOf(exporter.Formats())),
+	Run: func(cmd *cobra.Command, args []string) {
+		format := args[0]
+		r := newRunner()
+		if len(args) == 1 {
+			assertOK(mdPath != "", "file is required")
+			rq := exportRequest(r, format, mdPath, reqName)
+			out, err := exporter.Export(format, rq)
+			assert(err, "failed to export")
+			fmt.Println(secrets.Redact(out))
+			return
+		}
+		assertOK(format == "postman", "only postman supports exporting a directory")
+		dir := args[1]
+		paths, err := runner.Collect(dir)
+		assert(err, "failed to collect apis")
+		entries := []exporter.PostmanEntry{}
+		for _, path := range paths {
+			rel, err := filepath.Rel(dir, path)
+			assert(err, "failed to get relative path")
+			entryPath := strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+			names, err := parser.RequestNames(path)
+			assert(err, "failed to parse %s", path)
+			if len(names) == 0 {
+				entries = append(entries, exporter.PostmanEntry{
+					Path:    entryPath,
+					Request: exportRequest(r, format, path, ""),
+				})
+			}
+			for _, name := range names {
+				entries = append(entries, exporter.PostmanEntry{
+					Path:    entryPath + "/" + name,
+					Request: exportRequest(r, format, path, name),
+				})
+			}
+		}
+		absDir, err := filepath.Abs(dir)
+		assert(err, "failed to get absolute path")
+		out, err := exporter.Postman(filepath.Base(absDir), entries)
+		assert(err, "failed to export")
+		fmt.Println(secrets.Redact(out))
+	},
+}
+
+func firstArgOneOf(values []string) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) > 0 && !slices.Contains(values, args[0]) {
+			return fmt.Errorf("unknown %s, supported: %s", args[0], strings.Join(values, ", "))
+		}
+		return nil
+	}
+}
+
+func exportRequest(r *runner.Runner, format, path, name string) exporter.Request {
+	cliVars := map[string]string{}
+	for k, v := range vars {
+		cliVars[k] = v
+	}
+	fileData, allFields, dt, err := r.PrepareRequest(path, name, cliVars)
+	assert(err, "failed to prepare %s", path)
+	assertOK(dt.GetName() == "http", "%s: only the http type can be exported, got %s", path, fileData.Typ.Typ)
+	rqName := allFields.GetCurrentFile()
+	if fileData.Nam != "" {
+		rqName = fileData.Nam
+	}
+	rq, err := exporter.FromVars(rqName, allFields)
+	assert(err, "failed to export %s", path)
+	for _, w := range exporter.Unsupported(format, rq) {
+		fmt.Fprintf(os.Stderr, "warning: %s: %s\n", rqName, w)
+	}
+	return rq
+}
+
+var sessionCmd = &cobra.Command{
+	Use:   "session [dir]",
+	Short: "shows the session store of the directory (values saved from after sections marked with :session)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		path := runner.SessionPath(filepath.Join(dir, "api.md"), envName)
+		if clearSession {
+			err := session.Clear(path)
+			assert(err, "failed to clear session")
+			return
+		}
+		s, err := session.Load(path)
+		assert(err, "failed to load session")
+		for _, k := range slices.Sorted(maps.Keys(s)) {
+			v := s[k]
+			if v.Secret {
+				v.Val = secrets.Mask
+			}
+			fmt.Printf("%s=%s (%s)\n", k, v.Val, v.Updated.Format(time.RFC3339))
+		}
+	},
+}
+
+var cookiesCmd = &cobra.Command{
+	Use:   "cookies [dir]",
+	Short: "shows the cookie jars of the directory (used by the http type with cookies or cookieJar fields)",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+		resultFolder := filepath.Join(dir, runner.ResultFolder)
+		paths := []string{cookies.Path(resultFolder, jarName)}
+		if jarName == "" {
+			var err error
+			paths, err = filepath.Glob(cookies.Path(resultFolder, "*"))
+			assert(err, "failed to find cookie jars")
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		if !clearCookies {
+			fmt.Fprintln(w, "JAR\tDOMAIN\tPATH\tCOOKIE\tEXPIRES")
+		}
+		for _, path := range paths {
+			if clearCookies {
+				err := os.Remove(path)
+				if !os.IsNotExist(err) {
+					assert(err, "failed to clear %s", path)
 				}
-				counter++
+				continue
+			}
+			jar, err := cookies.Load(path)
+			assert(err, "failed to load %s", path)
+			name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+			for _, e := range jar.Entries() {
+				expires := "session"
+				if !e.Expires.IsZero() {
+					expires = e.Expires.Format(time.RFC3339)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s=%s\t%s\n", name, e.Domain, e.Path, e.Name, secrets.Redact(e.Value), expires)
 			}
 		}
-		err = os.MkdirAll(resdir, 0o755)
-		assert(err, "failed to create result dir")
-		err = dt.Run(allFields)
-		assert(err, "failed to run")
-		varsFile := filepath.Join(resdir, ".vars")
-		jsonVarsRaw, err := json.MarshalIndent(allFields, "", " ")
-		assert(err, "failed to convert fields to json")
-		err = os.WriteFile(varsFile, jsonVarsRaw, 0x775)
-		assert(err, "failed to write vars")
-		err = fileData.After.Compute(allFields, true)
-		assert(err, "failed to compute after")
-		for _, v := range fileData.After {
-			afterField := filepath.Join(resdir, v.Nam)
-			err = os.WriteFile(afterField, []byte(allFields[v.Nam]), 0x775)
-			assert(err, "failed to write %s", v.Nam)
+		w.Flush()
+	},
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "checks the api file and prints the problems in format file:line: severity: message",
+	Run: func(cmd *cobra.Command, args []string) {
+		allFields, _ := prepareVars()
+		dts, err := types.GetDefinedTypes(cfgPath)
+		assert(err, "failed to get defined types")
+		diags, err := lint.Lint(mdPath, allFields, dts)
+		assert(err, "failed to lint")
+		for _, d := range diags {
+			fmt.Printf("%s:%d: %s: %s\n", mdPath, d.Line, d.Severity, d.Msg)
+		}
+		if diags.HasErrors() {
+			os.Exit(1)
 		}
-		fmt.Println(allFields.GetResultDir())
 	},
 }
 
@@ -250,15 +504,45 @@ func main() {
 	defineFileFlag(varsCmd)
 	defineFileFlag(runCmd)
 	defineFileFlag(compileCmd)
+	defineFileFlag(lintCmd)
 	runCmd.Flags().StringToStringVar(&vars, "vars", nil, "key-value parameters (e.g. --vars key1=value1 --vars key2=value2)")
 	compileCmd.Flags().StringToStringVar(&vars, "vars", nil, "key-value parameters (e.g. --vars key1=value1 --vars key2=value2)")
 	varsCmd.Flags().StringToStringVar(&vars, "vars", nil, "key-value parameters (e.g. --vars key1=value1 --vars key2=value2)")
+	lintCmd.Flags().StringToStringVar(&vars, "vars", nil, "key-value parameters (e.g. --vars key1=value1 --vars key2=value2)")
+	runAllCmd.Flags().StringToStringVar(&vars, "vars", nil, "key-value parameters (e.g. --vars key1=value1 --vars key2=value2)")
+	runCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 5*time.Minute, "reuse results of required apis younger than this (0 to always rerun)")
+	runAllCmd.Flags().DurationVar(&cacheTTL, "cache-ttl", 5*time.Minute, "reuse results of required apis younger than this (0 to always rerun)")
+	for _, c := range []*cobra.Command{runCmd, compileCmd} {
+		c.Flags().BoolVarP(&interactive, "interactive", "i", false, "prompt for the vars which aren't set (a menu for lists)")
+	}
+	for _, c := range []*cobra.Command{runCmd, compileCmd, varsCmd, exportCmd} {
+		c.Flags().StringVar(&reqName, "name", "", "name of the request (the slug of its # heading) in a file declaring several requests")
+	}
+	for _, c := range []*cobra.Command{runCmd, runAllCmd, compileCmd, varsCmd, lintCmd} {
+		c.Flags().StringVar(&envName, "env", "", "env to load vars from (envs/<env>.md, envs/<env>.env, .env.<env> in the file dir or the config dir), precedence is cli > session > env > defaults")
+	}
+	exportCmd.Flags().StringVarP(&mdPath, "file", "f", "", "path to the file to export")
+	exportCmd.Flags().StringToStringVar(&vars, "vars", nil, "key-value parameters (e.g. --vars key1=value1 --vars key2=value2)")
+	exportCmd.Flags().StringVar(&envName, "env", "", "env to load vars from")
+	sessionCmd.Flags().StringVar(&envName, "env", "", "env of the session")
+	sessionCmd.Flags().BoolVar(&clearSession, "clear", false, "remove all the values from the session")
+	cookiesCmd.Flags().StringVar(&jarName, "jar", "", "name of the jar (the cookieJar field or the file name), all the jars if empty")
+	cookiesCmd.Flags().BoolVar(&clearCookies, "clear", false, "remove the cookies")
+	importCmd.Flags().StringVarP(&outDir, "out", "o", "", "directory to write the api files to (prints a single api to stdout if empty)")
+	runAllCmd.Flags().IntVar(&parallel, "parallel", 1, "number of apis to run at the same time")
 	rootCmd.AddCommand(varsCmd)
 	rootCmd.AddCommand(typesCmd)
 	rootCmd.AddCommand(varTypesCmd)
+	rootCmd.AddCommand(assertTypesCmd)
 	rootCmd.AddCommand(generateCmd)
 	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(runAllCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(sessionCmd)
+	rootCmd.AddCommand(cookiesCmd)
 	rootCmd.AddCommand(compileCmd)
+	rootCmd.AddCommand(lintCmd)
 	rootCmd.AddCommand(typeVarsCmd)
 
 	dirname, err := os.UserHomeDir()